@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"log"
 	"net/http"
 	"time"
@@ -15,6 +17,14 @@ import (
 
 func main() {
 	cfg := config.Load()
+	if cfg.SessionSecret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			log.Fatalf("session secret error: %v", err)
+		}
+		cfg.SessionSecret = hex.EncodeToString(buf)
+		log.Printf("SESSION_SECRET not set, using a random secret; sessions will not survive restarts")
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -24,15 +34,24 @@ func main() {
 	}
 	defer pool.Close()
 
-	if err := db.Migrate(context.Background(), pool); err != nil {
+	if err := db.Migrate(context.Background(), pool, cfg.PaperStartingBalance); err != nil {
 		log.Fatalf("db migrate error: %v", err)
 	}
 
 	r := repo.New(pool)
-	svc := service.New(r)
+	svc, err := service.New(r, cfg)
+	if err != nil {
+		log.Fatalf("service init error: %v", err)
+	}
 	h := httpserver.NewHandler(svc)
 
-	go httpserver.ServeWS(":" + cfg.WsPort)
+	go svc.WatchAgentApprovals(context.Background(), time.Minute)
+	go svc.WatchFunding(context.Background(), time.Minute)
+	go svc.WatchPaperOrders(context.Background(), 5*time.Second)
+	go svc.WatchReconciliation(context.Background(), cfg.ReconcileInterval)
+	go svc.ListenChanges(context.Background())
+	go svc.WatchEvents(context.Background(), time.Hour)
+	go h.ServeWS(":"+cfg.WsPort, cfg.WSAllowedOrigins)
 
 	addr := ":" + cfg.GoPort
 	log.Printf("api listening on %s", addr)
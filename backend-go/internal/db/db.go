@@ -19,7 +19,9 @@ func Connect(ctx context.Context, url string) (*pgxpool.Pool, error) {
 	return pool, nil
 }
 
-func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
+// Migrate brings the schema up to date. Accounts created before the ledger
+// are credited startingBalance, as EnsureAccount does for new ones.
+func Migrate(ctx context.Context, pool *pgxpool.Pool, startingBalance float64) error {
 	statements := []string{
 		`CREATE TABLE IF NOT EXISTS fills (
 			id BIGSERIAL PRIMARY KEY,
@@ -97,8 +99,187 @@ func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
 			client_tag TEXT NOT NULL DEFAULT '',
 			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
 		);`,
-		`INSERT INTO control_state (id, bias) VALUES (1, 'Hybrid') ON CONFLICT (id) DO NOTHING;`,
-		`INSERT INTO strategy_runtime (id) VALUES (1) ON CONFLICT (id) DO NOTHING;`,
+		`CREATE TABLE IF NOT EXISTS wallet_nonces (
+			nonce TEXT PRIMARY KEY,
+			issued_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			expires_at TIMESTAMPTZ NOT NULL,
+			used_at TIMESTAMPTZ
+		);`,
+		`CREATE TABLE IF NOT EXISTS accounts (
+			address TEXT PRIMARY KEY,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+		`ALTER TABLE fills ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT '';`,
+		`CREATE INDEX IF NOT EXISTS fills_account_created_idx ON fills (account, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS orders_account_created_idx ON orders (account, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS reviews_account_idx ON reviews (account);`,
+		`CREATE INDEX IF NOT EXISTS wallet_sessions_address_idx ON wallet_sessions (address, updated_at DESC);`,
+		`ALTER TABLE control_state ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT '';`,
+		`CREATE SEQUENCE IF NOT EXISTS control_state_id_seq START 2 OWNED BY control_state.id;`,
+		`ALTER TABLE control_state ALTER COLUMN id TYPE BIGINT, ALTER COLUMN id SET DEFAULT nextval('control_state_id_seq');`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS control_state_account_key ON control_state (account);`,
+		`ALTER TABLE strategy_runtime ADD COLUMN IF NOT EXISTS account TEXT NOT NULL DEFAULT '';`,
+		`CREATE SEQUENCE IF NOT EXISTS strategy_runtime_id_seq START 2 OWNED BY strategy_runtime.id;`,
+		`ALTER TABLE strategy_runtime ALTER COLUMN id TYPE BIGINT, ALTER COLUMN id SET DEFAULT nextval('strategy_runtime_id_seq');`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS strategy_runtime_account_key ON strategy_runtime (account);`,
+		`CREATE TABLE IF NOT EXISTS agent_keys (
+			id BIGSERIAL PRIMARY KEY,
+			account TEXT NOT NULL,
+			agent_address TEXT NOT NULL,
+			sealed_key BYTEA NOT NULL,
+			status TEXT NOT NULL DEFAULT 'active',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS agent_keys_active_key ON agent_keys (account) WHERE status = 'active';`,
+		`ALTER TABLE agent_keys ADD COLUMN IF NOT EXISTS valid_until TIMESTAMPTZ;`,
+		`ALTER TABLE agent_keys ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMPTZ;`,
+		`ALTER TABLE agent_keys ADD COLUMN IF NOT EXISTS revoked_reason TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS filled_size DOUBLE PRECISION NOT NULL DEFAULT 0;`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();`,
+		`CREATE TABLE IF NOT EXISTS order_events (
+			id BIGSERIAL PRIMARY KEY,
+			order_id BIGINT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			account TEXT NOT NULL,
+			event TEXT NOT NULL,
+			from_status TEXT NOT NULL DEFAULT '',
+			to_status TEXT NOT NULL,
+			detail JSONB NOT NULL DEFAULT '{}'::jsonb,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+		`CREATE INDEX IF NOT EXISTS order_events_order_idx ON order_events (order_id, id);`,
+		`CREATE TABLE IF NOT EXISTS order_idempotency (
+			account TEXT NOT NULL,
+			key TEXT NOT NULL,
+			request_hash TEXT NOT NULL,
+			order_id BIGINT REFERENCES orders(id) ON DELETE CASCADE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (account, key)
+		);`,
+		`ALTER TABLE fills ADD COLUMN IF NOT EXISTS order_id BIGINT REFERENCES orders(id) ON DELETE SET NULL;`,
+		`CREATE INDEX IF NOT EXISTS fills_order_idx ON fills (order_id);`,
+		`CREATE TABLE IF NOT EXISTS positions (
+			account TEXT NOT NULL,
+			execution TEXT NOT NULL DEFAULT 'paper',
+			symbol TEXT NOT NULL,
+			side TEXT NOT NULL DEFAULT '',
+			size DOUBLE PRECISION NOT NULL DEFAULT 0,
+			avg_entry DOUBLE PRECISION NOT NULL DEFAULT 0,
+			realized_pnl DOUBLE PRECISION NOT NULL DEFAULT 0,
+			opened_at TIMESTAMPTZ,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (account, execution, symbol)
+		);`,
+		`CREATE TABLE IF NOT EXISTS ledger_entries (
+			id BIGSERIAL PRIMARY KEY,
+			account TEXT NOT NULL,
+			execution TEXT NOT NULL DEFAULT 'paper',
+			kind TEXT NOT NULL CHECK (kind IN ('deposit', 'withdrawal', 'realised_pnl', 'fee', 'funding', 'adjustment')),
+			amount DOUBLE PRECISION NOT NULL,
+			symbol TEXT NOT NULL DEFAULT '',
+			ref_type TEXT NOT NULL DEFAULT '',
+			ref_id BIGINT,
+			note TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+		`CREATE INDEX IF NOT EXISTS ledger_entries_account_created_idx ON ledger_entries (account, execution, created_at, id);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS ledger_entries_ref_idx ON ledger_entries (kind, ref_type, ref_id) WHERE ref_id IS NOT NULL;`,
+		`INSERT INTO ledger_entries (account, kind, amount, symbol, ref_type, ref_id, created_at)
+		SELECT account, 'realised_pnl', realized_pnl, symbol, 'fill', id, created_at
+		FROM fills
+		WHERE account <> '' AND realized_pnl <> 0
+		ON CONFLICT (kind, ref_type, ref_id) WHERE ref_id IS NOT NULL DO NOTHING;`,
+		`CREATE INDEX IF NOT EXISTS market_snapshots_symbol_captured_idx ON market_snapshots (symbol, captured_at DESC);`,
+		`ALTER TABLE positions ADD COLUMN IF NOT EXISTS cumulative_funding DOUBLE PRECISION NOT NULL DEFAULT 0;`,
+		`CREATE TABLE IF NOT EXISTS funding_payments (
+			id BIGSERIAL PRIMARY KEY,
+			account TEXT NOT NULL,
+			symbol TEXT NOT NULL,
+			period TIMESTAMPTZ NOT NULL,
+			side TEXT NOT NULL,
+			size DOUBLE PRECISION NOT NULL,
+			mark_price DOUBLE PRECISION NOT NULL,
+			rate DOUBLE PRECISION NOT NULL,
+			amount DOUBLE PRECISION NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			UNIQUE (account, symbol, period)
+		);`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS parent_id BIGINT REFERENCES orders (id);`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'entry';`,
+		`CREATE INDEX IF NOT EXISTS orders_parent_idx ON orders (parent_id) WHERE parent_id IS NOT NULL;`,
+		`CREATE INDEX IF NOT EXISTS orders_working_idx ON orders (symbol, created_at) WHERE status IN ('open', 'partially_filled');`,
+		`CREATE TABLE IF NOT EXISTS paper_cursor (
+			timeframe TEXT PRIMARY KEY,
+			last_snapshot_id BIGINT NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
+		`ALTER TABLE fills ADD COLUMN IF NOT EXISTS fee DOUBLE PRECISION NOT NULL DEFAULT 0;`,
+		`ALTER TABLE fills ADD COLUMN IF NOT EXISTS slippage DOUBLE PRECISION NOT NULL DEFAULT 0;`,
+		`ALTER TABLE fills ADD COLUMN IF NOT EXISTS liquidity TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS venue_order_id TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE fills ADD COLUMN IF NOT EXISTS venue_trade_id TEXT NOT NULL DEFAULT '';`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS fills_venue_trade_key ON fills (account, venue_trade_id) WHERE venue_trade_id <> '';`,
+		`CREATE INDEX IF NOT EXISTS orders_venue_order_idx ON orders (account, venue_order_id) WHERE venue_order_id <> '';`,
+		`CREATE TABLE IF NOT EXISTS reconciliation_reports (
+			id BIGSERIAL PRIMARY KEY,
+			account TEXT NOT NULL,
+			execution TEXT NOT NULL,
+			orders_checked INT NOT NULL DEFAULT 0,
+			orders_updated INT NOT NULL DEFAULT 0,
+			orders_orphaned BIGINT[] NOT NULL DEFAULT '{}',
+			fills_repaired TEXT[] NOT NULL DEFAULT '{}',
+			unknown_venue_orders TEXT[] NOT NULL DEFAULT '{}',
+			position_drift JSONB NOT NULL DEFAULT '[]'::jsonb,
+			error TEXT NOT NULL DEFAULT '',
+			started_at TIMESTAMPTZ NOT NULL,
+			finished_at TIMESTAMPTZ NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS reconciliation_reports_started_idx ON reconciliation_reports (started_at DESC);`,
+		`CREATE OR REPLACE FUNCTION notify_change() RETURNS trigger AS $$
+		DECLARE
+			r jsonb := to_jsonb(NEW);
+		BEGIN
+			PERFORM pg_notify('autotrade_changes', jsonb_build_object(
+				'table', TG_TABLE_NAME,
+				'op', lower(TG_OP),
+				'id', COALESCE((r->>'id')::bigint, 0),
+				'account', COALESCE(r->>'account', ''),
+				'symbol', COALESCE(r->>'symbol', ''),
+				'timeframe', COALESCE(r->>'timeframe', ''),
+				'at', clock_timestamp()
+			)::text);
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql;`,
+		`CREATE OR REPLACE TRIGGER orders_notify AFTER INSERT OR UPDATE ON orders FOR EACH ROW EXECUTE FUNCTION notify_change();`,
+		`CREATE OR REPLACE TRIGGER fills_notify AFTER INSERT OR UPDATE ON fills FOR EACH ROW EXECUTE FUNCTION notify_change();`,
+		`CREATE OR REPLACE TRIGGER strategy_runtime_notify AFTER INSERT OR UPDATE ON strategy_runtime FOR EACH ROW EXECUTE FUNCTION notify_change();`,
+		`CREATE OR REPLACE TRIGGER control_state_notify AFTER INSERT OR UPDATE ON control_state FOR EACH ROW EXECUTE FUNCTION notify_change();`,
+		`CREATE OR REPLACE TRIGGER market_snapshots_notify AFTER INSERT ON market_snapshots FOR EACH ROW EXECUTE FUNCTION notify_change();`,
+		`CREATE TABLE IF NOT EXISTS event_sequences (
+			account TEXT PRIMARY KEY,
+			seq BIGINT NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS ws_events (
+			account TEXT NOT NULL,
+			seq BIGINT NOT NULL,
+			topic TEXT NOT NULL,
+			data JSONB NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (account, seq)
+		);`,
+		`CREATE INDEX IF NOT EXISTS ws_events_created_idx ON ws_events (created_at);`,
+		`CREATE TABLE IF NOT EXISTS risk_limits (
+			account TEXT PRIMARY KEY,
+			max_order_notional DOUBLE PRECISION NOT NULL DEFAULT 0,
+			max_open_per_symbol INTEGER NOT NULL DEFAULT 0,
+			max_leverage DOUBLE PRECISION NOT NULL DEFAULT 0,
+			max_loss_at_stop_pct DOUBLE PRECISION NOT NULL DEFAULT 0,
+			allowed_symbols TEXT[] NOT NULL DEFAULT '{}',
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);`,
 	}
 
 	for _, statement := range statements {
@@ -107,6 +288,20 @@ func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
 		}
 	}
 
+	if startingBalance > 0 {
+		if _, err := pool.Exec(ctx, `
+			INSERT INTO ledger_entries (account, kind, amount, note)
+			SELECT address, 'deposit', $1, 'paper starting balance'
+			FROM accounts
+			WHERE NOT EXISTS (
+				SELECT 1 FROM ledger_entries l
+				WHERE l.account = accounts.address AND l.execution = 'paper' AND l.kind = 'deposit'
+			);
+		`, startingBalance); err != nil {
+			return err
+		}
+	}
+
 	if _, err := pool.Exec(ctx, `
 		INSERT INTO fills (symbol, side, price, size, realized_pnl, status)
 		SELECT 'BTC', 'Buy', 102345.5, 0.01, 12.7, 'closed'
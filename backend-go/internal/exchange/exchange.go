@@ -0,0 +1,76 @@
+// Package exchange is the boundary between the order service and a venue.
+package exchange
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"autotrade/backend-go/internal/model"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// ErrRejected wraps a venue's refusal of an otherwise well-formed request.
+var ErrRejected = errors.New("rejected by venue")
+
+// ErrNotFound means the venue has no record of the order.
+var ErrNotFound = errors.New("order not found on venue")
+
+// OrderRequest carries an order and the credentials to act on it. Signer is
+// the account's agent key; venues that do not need one ignore it.
+type OrderRequest struct {
+	Account string
+	Signer  *secp256k1.PrivateKey
+	Order   model.Order
+	// Legs are the entry's stop-loss and take-profit child orders, placed
+	// with it as one bracket.
+	Legs []model.Order
+}
+
+type Placement struct {
+	VenueOrderID string
+	Status       string // one of the model.Order* statuses
+	FilledSize   float64
+	AvgPrice     float64
+	// LegOrderIDs holds the venue ids of the request's Legs in order; an id
+	// is empty while the venue holds the leg back until the entry fills.
+	LegOrderIDs []string
+}
+
+type OrderState struct {
+	VenueOrderID string
+	Symbol       string
+	Status       string
+	FilledSize   float64
+}
+
+type Fill struct {
+	VenueOrderID string
+	TradeID      string
+	Symbol       string
+	Side         string
+	Price        float64
+	Size         float64
+	Fee          float64
+	Liquidity    string // maker or taker
+	Time         time.Time
+}
+
+// PositionState is a venue position; Size is signed, negative for shorts.
+type PositionState struct {
+	Symbol   string
+	Size     float64
+	AvgEntry float64
+}
+
+type Executor interface {
+	PlaceOrder(ctx context.Context, req OrderRequest) (Placement, error)
+	CancelOrder(ctx context.Context, req OrderRequest) error
+	OrderStatus(ctx context.Context, account, venueOrderID string) (OrderState, error)
+	// OrderStatusByClientID finds an order by the local order id it was
+	// placed with, for orders whose placement response never arrived.
+	OrderStatusByClientID(ctx context.Context, account string, orderID int64) (OrderState, error)
+	Fills(ctx context.Context, account string, since time.Time) ([]Fill, error)
+	OpenOrders(ctx context.Context, account string) ([]OrderState, error)
+	Positions(ctx context.Context, account string) ([]PositionState, error)
+}
@@ -0,0 +1,43 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type ctxKey int
+
+const accountKey ctxKey = iota
+
+func (h *Handler) requireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || strings.TrimSpace(token) == "" {
+			respondErr(w, http.StatusUnauthorized, errors.New("missing bearer token"))
+			return
+		}
+		account, err := h.svc.Authenticate(strings.TrimSpace(token))
+		if err != nil {
+			respondErr(w, http.StatusUnauthorized, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), accountKey, account)))
+	})
+}
+
+func (h *Handler) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.svc.IsAdmin(accountFrom(r)) {
+			respondErr(w, http.StatusForbidden, errors.New("admin only"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func accountFrom(r *http.Request) string {
+	account, _ := r.Context().Value(accountKey).(string)
+	return account
+}
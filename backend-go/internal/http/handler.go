@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"autotrade/backend-go/internal/model"
+	"autotrade/backend-go/internal/risk"
 	"autotrade/backend-go/internal/service"
 	"github.com/gorilla/mux"
 )
@@ -20,29 +21,46 @@ func NewHandler(svc *service.Service) *Handler {
 
 func (h *Handler) Router() http.Handler {
 	r := mux.NewRouter()
-	r.Use(cors)
 
 	r.HandleFunc("/healthz", h.health).Methods(http.MethodGet)
-	r.HandleFunc("/v1/me/state", h.getState).Methods(http.MethodGet)
-	r.HandleFunc("/v1/me/fills", h.getFills).Methods(http.MethodGet)
-	r.HandleFunc("/v1/me/review", h.postReview).Methods(http.MethodPost)
-	r.HandleFunc("/v1/strategy/derives", h.getDerives).Methods(http.MethodGet)
-	r.HandleFunc("/v1/strategy/status", h.getStrategyStatus).Methods(http.MethodGet)
-	r.HandleFunc("/v1/strategy/auto-trade", h.patchAutoTrade).Methods(http.MethodPatch)
-	r.HandleFunc("/v1/control/bias", h.patchBias).Methods(http.MethodPatch)
-	r.HandleFunc("/v1/auth/wallet/session", h.getWalletSession).Methods(http.MethodGet)
+	r.HandleFunc("/v1/auth/wallet/nonce", h.getWalletNonce).Methods(http.MethodGet)
 	r.HandleFunc("/v1/auth/wallet/connect", h.postWalletConnect).Methods(http.MethodPost)
-	r.HandleFunc("/v1/auth/approve-agent", h.postApproveAgent).Methods(http.MethodPost)
-	r.HandleFunc("/v1/trade/order", h.postOrder).Methods(http.MethodPost)
-	r.HandleFunc("/v1/trade/orders", h.getOrders).Methods(http.MethodGet)
-	return r
+
+	authed := r.NewRoute().Subrouter()
+	authed.Use(h.requireSession)
+	authed.HandleFunc("/v1/me/state", h.getState).Methods(http.MethodGet)
+	authed.HandleFunc("/v1/me/fills", h.getFills).Methods(http.MethodGet)
+	authed.HandleFunc("/v1/me/review", h.postReview).Methods(http.MethodPost)
+	authed.HandleFunc("/v1/me/ledger", h.getLedger).Methods(http.MethodGet)
+	authed.HandleFunc("/v1/me/positions", h.getPositions).Methods(http.MethodGet)
+	authed.HandleFunc("/v1/me/positions/{symbol}", h.getPosition).Methods(http.MethodGet)
+	authed.HandleFunc("/v1/strategy/derives", h.getDerives).Methods(http.MethodGet)
+	authed.HandleFunc("/v1/strategy/status", h.getStrategyStatus).Methods(http.MethodGet)
+	authed.HandleFunc("/v1/strategy/auto-trade", h.patchAutoTrade).Methods(http.MethodPatch)
+	authed.HandleFunc("/v1/control/bias", h.patchBias).Methods(http.MethodPatch)
+	authed.HandleFunc("/v1/control/risk", h.getRiskLimits).Methods(http.MethodGet)
+	authed.HandleFunc("/v1/control/risk", h.putRiskLimits).Methods(http.MethodPut)
+	authed.HandleFunc("/v1/auth/wallet/session", h.getWalletSession).Methods(http.MethodGet)
+	authed.HandleFunc("/v1/auth/approve-agent", h.postApproveAgent).Methods(http.MethodPost)
+	authed.HandleFunc("/v1/auth/agent", h.deleteAgent).Methods(http.MethodDelete)
+	authed.HandleFunc("/v1/trade/size", h.postSize).Methods(http.MethodPost)
+	authed.HandleFunc("/v1/trade/order", h.postOrder).Methods(http.MethodPost)
+	authed.HandleFunc("/v1/trade/orders", h.getOrders).Methods(http.MethodGet)
+	authed.HandleFunc("/v1/trade/orders/{id:[0-9]+}", h.getOrder).Methods(http.MethodGet)
+	authed.HandleFunc("/v1/trade/orders/{id:[0-9]+}", h.patchOrder).Methods(http.MethodPatch)
+	authed.HandleFunc("/v1/trade/orders/{id:[0-9]+}/cancel", h.postCancelOrder).Methods(http.MethodPost)
+
+	admin := authed.PathPrefix("/v1/admin").Subrouter()
+	admin.Use(h.requireAdmin)
+	admin.HandleFunc("/reconciliation", h.getReconciliation).Methods(http.MethodGet)
+	return cors(r)
 }
 
 func cors(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PATCH,OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
+		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -56,9 +74,9 @@ func (h *Handler) health(w http.ResponseWriter, _ *http.Request) {
 }
 
 func (h *Handler) getState(w http.ResponseWriter, r *http.Request) {
-	data, err := h.svc.State(r.Context())
+	data, err := h.svc.State(r.Context(), accountFrom(r), r.URL.Query().Get("execution"))
 	if err != nil {
-		respondErr(w, http.StatusInternalServerError, err)
+		respondServiceErr(w, err)
 		return
 	}
 	respondJSON(w, http.StatusOK, data)
@@ -66,7 +84,7 @@ func (h *Handler) getState(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) getFills(w http.ResponseWriter, r *http.Request) {
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-	fills, err := h.svc.Fills(r.Context(), limit)
+	fills, err := h.svc.Fills(r.Context(), accountFrom(r), limit)
 	if err != nil {
 		respondErr(w, http.StatusInternalServerError, err)
 		return
@@ -74,18 +92,25 @@ func (h *Handler) getFills(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]any{"fills": fills})
 }
 
+func (h *Handler) getLedger(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	entries, err := h.svc.Ledger(r.Context(), accountFrom(r), q.Get("execution"), q.Get("from"), q.Get("to"), q.Get("kind"), limit)
+	if err != nil {
+		respondServiceErr(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"entries": entries})
+}
+
 func (h *Handler) postReview(w http.ResponseWriter, r *http.Request) {
 	var in model.ReviewInput
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		respondErr(w, http.StatusBadRequest, err)
 		return
 	}
-	if err := h.svc.Review(r.Context(), in); err != nil {
-		if service.IsBadRequest(err) {
-			respondErr(w, http.StatusBadRequest, err)
-			return
-		}
-		respondErr(w, http.StatusInternalServerError, err)
+	if err := h.svc.Review(r.Context(), accountFrom(r), in); err != nil {
+		respondServiceErr(w, err)
 		return
 	}
 	respondJSON(w, http.StatusCreated, map[string]string{"status": "saved"})
@@ -108,19 +133,56 @@ func (h *Handler) patchBias(w http.ResponseWriter, r *http.Request) {
 		respondErr(w, http.StatusBadRequest, err)
 		return
 	}
-	if err := h.svc.SetBias(r.Context(), body.Bias); err != nil {
-		if service.IsBadRequest(err) {
-			respondErr(w, http.StatusBadRequest, err)
-			return
-		}
-		respondErr(w, http.StatusInternalServerError, err)
+	if err := h.svc.SetBias(r.Context(), accountFrom(r), body.Bias); err != nil {
+		respondServiceErr(w, err)
 		return
 	}
 	respondJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
+func (h *Handler) getPositions(w http.ResponseWriter, r *http.Request) {
+	items, err := h.svc.Positions(r.Context(), accountFrom(r), r.URL.Query().Get("execution"))
+	if err != nil {
+		respondServiceErr(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"positions": items})
+}
+
+func (h *Handler) getPosition(w http.ResponseWriter, r *http.Request) {
+	p, err := h.svc.Position(r.Context(), accountFrom(r), r.URL.Query().Get("execution"), mux.Vars(r)["symbol"])
+	if err != nil {
+		respondServiceErr(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"position": p})
+}
+
+func (h *Handler) getRiskLimits(w http.ResponseWriter, r *http.Request) {
+	limits, err := h.svc.RiskLimits(r.Context(), accountFrom(r))
+	if err != nil {
+		respondServiceErr(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"limits": limits})
+}
+
+func (h *Handler) putRiskLimits(w http.ResponseWriter, r *http.Request) {
+	var body risk.Limits
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondErr(w, http.StatusBadRequest, err)
+		return
+	}
+	limits, err := h.svc.SetRiskLimits(r.Context(), accountFrom(r), body)
+	if err != nil {
+		respondServiceErr(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"limits": limits})
+}
+
 func (h *Handler) getWalletSession(w http.ResponseWriter, r *http.Request) {
-	ws, err := h.svc.WalletSession(r.Context())
+	ws, err := h.svc.WalletSession(r.Context(), accountFrom(r))
 	if err != nil {
 		respondErr(w, http.StatusInternalServerError, err)
 		return
@@ -128,6 +190,15 @@ func (h *Handler) getWalletSession(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]any{"session": ws})
 }
 
+func (h *Handler) getWalletNonce(w http.ResponseWriter, r *http.Request) {
+	nonce, err := h.svc.WalletNonce(r.Context())
+	if err != nil {
+		respondErr(w, http.StatusInternalServerError, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, nonce)
+}
+
 func (h *Handler) postWalletConnect(w http.ResponseWriter, r *http.Request) {
 	var body struct {
 		Address   string `json:"address"`
@@ -138,32 +209,47 @@ func (h *Handler) postWalletConnect(w http.ResponseWriter, r *http.Request) {
 		respondErr(w, http.StatusBadRequest, err)
 		return
 	}
-	if err := h.svc.ConnectWallet(r.Context(), body.Address, body.Signature, body.Message); err != nil {
-		if service.IsBadRequest(err) {
-			respondErr(w, http.StatusBadRequest, err)
-			return
-		}
-		respondErr(w, http.StatusInternalServerError, err)
+	session, err := h.svc.ConnectWallet(r.Context(), body.Address, body.Signature, body.Message)
+	if err != nil {
+		respondServiceErr(w, err)
 		return
 	}
-	respondJSON(w, http.StatusCreated, map[string]string{"status": "connected"})
+	respondJSON(w, http.StatusCreated, map[string]any{"status": "connected", "session": session})
 }
 
 func (h *Handler) postApproveAgent(w http.ResponseWriter, r *http.Request) {
-	agentPub, err := h.svc.ApproveAgent(r.Context())
+	approval, err := h.svc.ApproveAgent(r.Context(), accountFrom(r))
 	if err != nil {
-		if service.IsBadRequest(err) {
+		respondServiceErr(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{
+		"status":       "approved",
+		"agentPubKey":  approval.AgentAddress,
+		"agentAddress": approval.AgentAddress,
+		"validUntil":   approval.ValidUntil,
+	})
+}
+
+func (h *Handler) deleteAgent(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 			respondErr(w, http.StatusBadRequest, err)
 			return
 		}
-		respondErr(w, http.StatusInternalServerError, err)
+	}
+	if err := h.svc.RevokeAgent(r.Context(), accountFrom(r), body.Reason); err != nil {
+		respondServiceErr(w, err)
 		return
 	}
-	respondJSON(w, http.StatusOK, map[string]string{"status": "approved", "agentPubKey": agentPub})
+	respondJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
 }
 
 func (h *Handler) getStrategyStatus(w http.ResponseWriter, r *http.Request) {
-	status, err := h.svc.StrategyStatus(r.Context())
+	status, err := h.svc.StrategyStatus(r.Context(), accountFrom(r))
 	if err != nil {
 		respondErr(w, http.StatusInternalServerError, err)
 		return
@@ -179,34 +265,48 @@ func (h *Handler) patchAutoTrade(w http.ResponseWriter, r *http.Request) {
 		respondErr(w, http.StatusBadRequest, err)
 		return
 	}
-	if err := h.svc.SetAutoTrading(r.Context(), body.Enabled); err != nil {
-		respondErr(w, http.StatusInternalServerError, err)
+	if err := h.svc.SetAutoTrading(r.Context(), accountFrom(r), body.Enabled); err != nil {
+		respondServiceErr(w, err)
 		return
 	}
 	respondJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
+func (h *Handler) postSize(w http.ResponseWriter, r *http.Request) {
+	var body model.SizeInput
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondErr(w, http.StatusBadRequest, err)
+		return
+	}
+	quote, err := h.svc.SizePosition(r.Context(), accountFrom(r), body)
+	if err != nil {
+		respondServiceErr(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, quote)
+}
+
 func (h *Handler) postOrder(w http.ResponseWriter, r *http.Request) {
 	var in model.OrderInput
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		respondErr(w, http.StatusBadRequest, err)
 		return
 	}
-	id, err := h.svc.CreateOrder(r.Context(), in)
+	id, replayed, err := h.svc.CreateOrder(r.Context(), accountFrom(r), in, r.Header.Get("Idempotency-Key"))
 	if err != nil {
-		if service.IsBadRequest(err) {
-			respondErr(w, http.StatusBadRequest, err)
-			return
-		}
-		respondErr(w, http.StatusInternalServerError, err)
+		respondServiceErr(w, err)
 		return
 	}
-	respondJSON(w, http.StatusCreated, map[string]any{"status": "placed", "orderId": id})
+	code := http.StatusCreated
+	if replayed {
+		code = http.StatusOK
+	}
+	respondJSON(w, code, map[string]any{"status": "placed", "orderId": id, "replayed": replayed})
 }
 
 func (h *Handler) getOrders(w http.ResponseWriter, r *http.Request) {
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-	items, err := h.svc.Orders(r.Context(), limit)
+	items, err := h.svc.Orders(r.Context(), accountFrom(r), limit)
 	if err != nil {
 		respondErr(w, http.StatusInternalServerError, err)
 		return
@@ -214,6 +314,52 @@ func (h *Handler) getOrders(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]any{"orders": items})
 }
 
+func (h *Handler) getOrder(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	order, events, err := h.svc.Order(r.Context(), accountFrom(r), id)
+	if err != nil {
+		respondServiceErr(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"order": order, "events": events})
+}
+
+func (h *Handler) patchOrder(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	var in model.OrderAmend
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondErr(w, http.StatusBadRequest, err)
+		return
+	}
+	order, err := h.svc.AmendOrder(r.Context(), accountFrom(r), id, in)
+	if err != nil {
+		respondServiceErr(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"status": "amended", "order": order})
+}
+
+func (h *Handler) postCancelOrder(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	order, err := h.svc.CancelOrder(r.Context(), accountFrom(r), id)
+	if err != nil {
+		respondServiceErr(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"status": "cancelled", "order": order})
+}
+
+func (h *Handler) getReconciliation(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	reports, err := h.svc.ReconciliationReports(r.Context(), q.Get("account"), limit)
+	if err != nil {
+		respondServiceErr(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{"reports": reports})
+}
+
 func respondJSON(w http.ResponseWriter, code int, payload any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -223,3 +369,30 @@ func respondJSON(w http.ResponseWriter, code int, payload any) {
 func respondErr(w http.ResponseWriter, code int, err error) {
 	respondJSON(w, code, map[string]string{"error": err.Error()})
 }
+
+func respondServiceErr(w http.ResponseWriter, err error) {
+	if fields, ok := service.ValidationFields(err); ok {
+		respondJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error(), "fields": fields})
+		return
+	}
+	if rejections, ok := service.RiskRejections(err); ok {
+		respondJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": err.Error(), "rejections": rejections})
+		return
+	}
+	switch {
+	case service.IsBadRequest(err):
+		respondErr(w, http.StatusBadRequest, err)
+	case service.IsUnauthorized(err):
+		if code, ok := service.UnauthorizedCode(err); ok {
+			respondJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error(), "code": code})
+			return
+		}
+		respondErr(w, http.StatusUnauthorized, err)
+	case service.IsNotFound(err):
+		respondErr(w, http.StatusNotFound, err)
+	case service.IsConflict(err):
+		respondErr(w, http.StatusConflict, err)
+	default:
+		respondErr(w, http.StatusInternalServerError, err)
+	}
+}
@@ -1,37 +1,246 @@
 package http
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"autotrade/backend-go/internal/realtime"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = 45 * time.Second
+	wsMaxMessage = 4096
+)
+
+const wsAuthWait = 10 * time.Second
+
+// wsUnauthorized is the close code sent for a missing or bad session token.
+const wsUnauthorized = 4401
+
+// wsRequest is a client command, e.g. {"type":"auth","token":"..."} or
+// {"type":"subscribe","topics":["orders"]} or {"type":"resume","resumeFrom":42}.
+type wsRequest struct {
+	Type       string   `json:"type"`
+	Token      string   `json:"token"`
+	Topics     []string `json:"topics"`
+	ResumeFrom int64    `json:"resumeFrom"`
+}
 
-func ServeWS(addr string) {
-	h := func(w http.ResponseWriter, r *http.Request) {
-		c, err := upgrader.Upgrade(w, r, nil)
+// ServeWS serves the event socket. Browsers must connect from one of
+// origins. Clients authenticate with their session token, as ?token= or in
+// a first auth message, and only receive events for their own account. A
+// reconnecting client passes ?topics= and ?resumeFrom=<seq> (or sends a
+// resume message) to be replayed what it missed.
+func (h *Handler) ServeWS(addr string, origins []string) {
+	upgrader := websocket.Upgrader{CheckOrigin: originAllowed(origins)}
+	hub := h.svc.Hub()
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var resumeFrom int64 = -1
+		if v := q.Get("resumeFrom"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				respondErr(w, http.StatusBadRequest, errors.New("resumeFrom must be a sequence number"))
+				return
+			}
+			resumeFrom = n
+		}
+		var account string
+		if token := q.Get("token"); token != "" {
+			var err error
+			if account, err = h.svc.Authenticate(token); err != nil {
+				respondErr(w, http.StatusUnauthorized, err)
+				return
+			}
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			return
 		}
-		defer c.Close()
-
-		ticker := time.NewTicker(3 * time.Second)
-		defer ticker.Stop()
-		for t := range ticker.C {
-			msg := map[string]any{"type": "heartbeat", "at": t.UTC().Format(time.RFC3339)}
-			if err := c.WriteJSON(msg); err != nil {
-				return
+		client := hub.Register()
+		go writePump(conn, client)
+		if account != "" {
+			client.SetAccount(account)
+			h.wsAuthenticated(r.Context(), client)
+			if v := q.Get("topics"); v != "" {
+				wsSubscribe(client, "subscribe", strings.Split(v, ","))
+			}
+			if resumeFrom >= 0 {
+				h.wsResume(r.Context(), client, resumeFrom)
 			}
 		}
+		h.readPump(r.Context(), conn, client)
+		hub.Unregister(client)
 	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", h)
+	mux.HandleFunc("/", serve)
 	log.Printf("ws listening on %s", addr)
 	if err := http.ListenAndServe(addr, mux); err != nil {
 		log.Printf("ws stopped: %v", err)
 	}
 }
+
+// originAllowed accepts requests without an Origin header, which browsers
+// always send, so non-browser clients only need a token.
+func originAllowed(origins []string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		return slices.ContainsFunc(origins, func(o string) bool { return o == "*" || strings.EqualFold(o, origin) })
+	}
+}
+
+// readPump handles auth and subscribe/unsubscribe commands until the
+// connection fails or the client is dropped. A client that has not
+// authenticated within wsAuthWait is disconnected.
+func (h *Handler) readPump(ctx context.Context, conn *websocket.Conn, client *realtime.Client) {
+	conn.SetReadLimit(wsMaxMessage)
+	wait := wsPongWait
+	if client.Account() == "" {
+		wait = wsAuthWait
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(wait))
+	conn.SetPongHandler(func(string) error {
+		// Pongs keep only authenticated clients alive; the auth deadline stands.
+		if client.Account() == "" {
+			return nil
+		}
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req wsRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			wsReply(client, map[string]any{"type": "error", "error": "invalid message"})
+			continue
+		}
+		if req.Type == "auth" {
+			if client.Account() != "" {
+				wsReply(client, map[string]any{"type": "error", "error": "already authenticated"})
+				continue
+			}
+			account, err := h.svc.Authenticate(req.Token)
+			if err != nil {
+				_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(wsUnauthorized, "unauthorized"), time.Now().Add(wsWriteWait))
+				return
+			}
+			client.SetAccount(account)
+			_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+			h.wsAuthenticated(ctx, client)
+			continue
+		}
+		if client.Account() == "" {
+			wsReply(client, map[string]any{"type": "error", "error": "authenticate first"})
+			continue
+		}
+		switch req.Type {
+		case "subscribe", "unsubscribe":
+			wsSubscribe(client, req.Type, req.Topics)
+		case "resume":
+			h.wsResume(ctx, client, req.ResumeFrom)
+		case "ping":
+			wsReply(client, map[string]any{"type": "pong", "at": time.Now().UTC().Format(time.RFC3339)})
+		default:
+			wsReply(client, map[string]any{"type": "error", "error": "unknown message type"})
+		}
+	}
+}
+
+// writePump is the connection's only writer: it drains the client's queue,
+// pings to keep the connection alive, and closes it once the client is done.
+func writePump(conn *websocket.Conn, client *realtime.Client) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+	for {
+		select {
+		case msg := <-client.Send():
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				client.Close()
+				return
+			}
+		case <-ticker.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				client.Close()
+				return
+			}
+		case <-client.Done():
+			if client.Dropped() {
+				_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "slow consumer"), time.Now().Add(wsWriteWait))
+			}
+			return
+		}
+	}
+}
+
+// wsAuthenticated confirms authentication with the account's current
+// sequence number, the point to resume from if nothing arrives before a
+// disconnect.
+func (h *Handler) wsAuthenticated(ctx context.Context, client *realtime.Client) {
+	seq, err := h.svc.Hub().LatestSeq(ctx, client.Account())
+	if err != nil {
+		log.Printf("ws: latest seq for %s: %v", client.Account(), err)
+	}
+	wsReply(client, map[string]any{"type": "authenticated", "account": client.Account(), "seq": seq})
+}
+
+func wsSubscribe(client *realtime.Client, action string, topics []string) {
+	invalid := make([]string, 0)
+	for _, topic := range topics {
+		switch {
+		case !realtime.ValidTopic(topic):
+			invalid = append(invalid, topic)
+		case action == "subscribe":
+			client.Subscribe(topic)
+		default:
+			client.Unsubscribe(topic)
+		}
+	}
+	if len(invalid) > 0 {
+		wsReply(client, map[string]any{"type": "error", "error": "unknown topics", "topics": invalid})
+	}
+	wsReply(client, map[string]any{"type": "subscriptions", "topics": client.Topics()})
+}
+
+// wsResume replays the subscribed events after seq, or tells the client to
+// reload its state over REST when they are no longer available.
+func (h *Handler) wsResume(ctx context.Context, client *realtime.Client, after int64) {
+	latest, ok, err := h.svc.Hub().Resume(ctx, client, after)
+	switch {
+	case err != nil:
+		log.Printf("ws: resume %s from %d: %v", client.Account(), after, err)
+		wsReply(client, map[string]any{"type": "error", "error": "resume failed"})
+	case !ok:
+		wsReply(client, map[string]any{"type": "snapshot_required", "seq": latest})
+	default:
+		wsReply(client, map[string]any{"type": "resumed", "seq": latest})
+	}
+}
+
+func wsReply(client *realtime.Client, msg any) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	client.Enqueue(b)
+}
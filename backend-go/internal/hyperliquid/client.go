@@ -0,0 +1,513 @@
+// Package hyperliquid executes orders on Hyperliquid perps through its
+// /exchange and /info HTTP API, signing actions with the account's agent key.
+package hyperliquid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"autotrade/backend-go/internal/exchange"
+	"autotrade/backend-go/internal/model"
+)
+
+type Config struct {
+	BaseURL string
+	Mainnet bool
+	// MarketSlippage bounds market and stop orders, which Hyperliquid takes
+	// as aggressive IOC limits, e.g. 0.05 for 5% through the reference price.
+	MarketSlippage float64
+	HTTPClient     *http.Client
+}
+
+type Client struct {
+	cfg  Config
+	http *http.Client
+
+	mu     sync.Mutex
+	assets map[string]asset
+}
+
+type asset struct {
+	index      int
+	szDecimals int
+}
+
+func New(cfg Config) *Client {
+	c := &Client{cfg: cfg, http: cfg.HTTPClient}
+	c.cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if c.http == nil {
+		c.http = &http.Client{Timeout: 10 * time.Second}
+	}
+	return c
+}
+
+var _ exchange.Executor = (*Client)(nil)
+
+func (c *Client) PlaceOrder(ctx context.Context, req exchange.OrderRequest) (exchange.Placement, error) {
+	if req.Signer == nil {
+		return exchange.Placement{}, fmt.Errorf("hyperliquid: agent key required")
+	}
+	o := req.Order
+	a, err := c.asset(ctx, o.Symbol)
+	if err != nil {
+		return exchange.Placement{}, err
+	}
+	isBuy := o.Side == model.SideBuy
+	entry := c.orderWire(a, isBuy, o.OrderType, o.EntryPrice, o.Size-o.FilledSize, false, "")
+	if o.ID > 0 {
+		// The cloid lets reconciliation find the order if this response is lost.
+		entry = append(entry, kv{"c", cloid(o.ID)})
+	}
+	orders := []any{entry}
+	grouping := "na"
+	// Entry brackets go out as Hyperliquid's native TP/SL group, each leg
+	// with its own local order's cloid.
+	if len(req.Legs) > 0 {
+		grouping = "normalTpsl"
+		for _, leg := range req.Legs {
+			tpsl := "sl"
+			if leg.Role == model.RoleTakeProfit {
+				tpsl = "tp"
+			}
+			wire := c.orderWire(a, leg.Side == model.SideBuy, model.OrderTypeStop, leg.EntryPrice, leg.Size, true, tpsl)
+			orders = append(orders, append(wire, kv{"c", cloid(leg.ID)}))
+		}
+	}
+	action := omap{{"type", "order"}, {"orders", orders}, {"grouping", grouping}}
+
+	var resp struct {
+		Type string `json:"type"`
+		Data struct {
+			Statuses []json.RawMessage `json:"statuses"`
+		} `json:"data"`
+	}
+	if err := c.exchange(ctx, req, action, &resp); err != nil {
+		return exchange.Placement{}, err
+	}
+	if len(resp.Data.Statuses) == 0 {
+		return exchange.Placement{}, fmt.Errorf("hyperliquid: empty order response")
+	}
+	var st struct {
+		Resting *struct {
+			Oid int64 `json:"oid"`
+		} `json:"resting"`
+		Filled *struct {
+			TotalSz string `json:"totalSz"`
+			AvgPx   string `json:"avgPx"`
+			Oid     int64  `json:"oid"`
+		} `json:"filled"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(resp.Data.Statuses[0], &st); err != nil {
+		return exchange.Placement{}, fmt.Errorf("hyperliquid: decode order status: %w", err)
+	}
+	legIDs := legOrderIDs(resp.Data.Statuses[1:], len(req.Legs))
+	switch {
+	case st.Error != "":
+		return exchange.Placement{}, fmt.Errorf("%w: %s", exchange.ErrRejected, st.Error)
+	case st.Filled != nil:
+		size, _ := strconv.ParseFloat(st.Filled.TotalSz, 64)
+		price, _ := strconv.ParseFloat(st.Filled.AvgPx, 64)
+		return exchange.Placement{
+			VenueOrderID: strconv.FormatInt(st.Filled.Oid, 10),
+			Status:       model.OrderFilled,
+			FilledSize:   size,
+			AvgPrice:     price,
+			LegOrderIDs:  legIDs,
+		}, nil
+	case st.Resting != nil:
+		return exchange.Placement{VenueOrderID: strconv.FormatInt(st.Resting.Oid, 10), Status: model.OrderOpen, LegOrderIDs: legIDs}, nil
+	}
+	return exchange.Placement{}, fmt.Errorf("hyperliquid: unrecognised order status %s", resp.Data.Statuses[0])
+}
+
+// legOrderIDs reads the statuses of n bracket legs. A leg that is resting
+// has an oid; one held until the entry fills is reported as a bare string
+// such as "waitingForFill" and gets an empty id.
+func legOrderIDs(statuses []json.RawMessage, n int) []string {
+	if n == 0 {
+		return nil
+	}
+	out := make([]string, n)
+	for i, raw := range statuses {
+		if i == n {
+			break
+		}
+		var st struct {
+			Resting *struct {
+				Oid int64 `json:"oid"`
+			} `json:"resting"`
+		}
+		if json.Unmarshal(raw, &st) == nil && st.Resting != nil {
+			out[i] = strconv.FormatInt(st.Resting.Oid, 10)
+		}
+	}
+	return out
+}
+
+func (c *Client) CancelOrder(ctx context.Context, req exchange.OrderRequest) error {
+	if req.Signer == nil {
+		return fmt.Errorf("hyperliquid: agent key required")
+	}
+	oid, err := strconv.ParseInt(req.Order.VenueOrderID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("hyperliquid: invalid venue order id %q", req.Order.VenueOrderID)
+	}
+	a, err := c.asset(ctx, req.Order.Symbol)
+	if err != nil {
+		return err
+	}
+	action := omap{{"type", "cancel"}, {"cancels", []any{omap{{"a", a.index}, {"o", oid}}}}}
+	var resp struct {
+		Data struct {
+			Statuses []json.RawMessage `json:"statuses"`
+		} `json:"data"`
+	}
+	if err := c.exchange(ctx, req, action, &resp); err != nil {
+		return err
+	}
+	for _, raw := range resp.Data.Statuses {
+		var st struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(raw, &st) == nil && st.Error != "" {
+			return fmt.Errorf("%w: %s", exchange.ErrRejected, st.Error)
+		}
+	}
+	return nil
+}
+
+func (c *Client) OrderStatus(ctx context.Context, account, venueOrderID string) (exchange.OrderState, error) {
+	oid, err := strconv.ParseInt(venueOrderID, 10, 64)
+	if err != nil {
+		return exchange.OrderState{}, fmt.Errorf("hyperliquid: invalid venue order id %q", venueOrderID)
+	}
+	return c.orderStatus(ctx, account, oid)
+}
+
+// OrderStatusByClientID looks the order up by the cloid it was placed with.
+func (c *Client) OrderStatusByClientID(ctx context.Context, account string, orderID int64) (exchange.OrderState, error) {
+	return c.orderStatus(ctx, account, cloid(orderID))
+}
+
+// orderStatus queries one order; oid is the venue's numeric id or a cloid.
+func (c *Client) orderStatus(ctx context.Context, account string, oid any) (exchange.OrderState, error) {
+	var resp struct {
+		Status string `json:"status"`
+		Order  struct {
+			Order struct {
+				Coin   string `json:"coin"`
+				Oid    int64  `json:"oid"`
+				Sz     string `json:"sz"`
+				OrigSz string `json:"origSz"`
+			} `json:"order"`
+			Status string `json:"status"`
+		} `json:"order"`
+	}
+	if err := c.info(ctx, map[string]any{"type": "orderStatus", "user": account, "oid": oid}, &resp); err != nil {
+		return exchange.OrderState{}, err
+	}
+	if resp.Status != "order" {
+		return exchange.OrderState{}, fmt.Errorf("hyperliquid: %w: %v", exchange.ErrNotFound, oid)
+	}
+	remaining, _ := strconv.ParseFloat(resp.Order.Order.Sz, 64)
+	original, _ := strconv.ParseFloat(resp.Order.Order.OrigSz, 64)
+	out := exchange.OrderState{VenueOrderID: strconv.FormatInt(resp.Order.Order.Oid, 10), Symbol: resp.Order.Order.Coin, FilledSize: math.Max(0, original-remaining)}
+	switch resp.Order.Status {
+	case "open", "triggered":
+		out.Status = model.OrderOpen
+		if out.FilledSize > 0 {
+			out.Status = model.OrderPartiallyFilled
+		}
+	case "filled":
+		out.Status = model.OrderFilled
+	case "rejected":
+		out.Status = model.OrderRejected
+	default:
+		// canceled, marginCanceled, reduceOnlyCanceled, ...
+		out.Status = model.OrderCancelled
+	}
+	return out, nil
+}
+
+func (c *Client) Fills(ctx context.Context, account string, since time.Time) ([]exchange.Fill, error) {
+	var resp []struct {
+		Coin    string `json:"coin"`
+		Px      string `json:"px"`
+		Sz      string `json:"sz"`
+		Side    string `json:"side"`
+		Time    int64  `json:"time"`
+		Oid     int64  `json:"oid"`
+		Tid     int64  `json:"tid"`
+		Fee     string `json:"fee"`
+		Crossed bool   `json:"crossed"`
+	}
+	req := map[string]any{"type": "userFillsByTime", "user": account, "startTime": since.UnixMilli()}
+	if err := c.info(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	out := make([]exchange.Fill, 0, len(resp))
+	for _, f := range resp {
+		price, _ := strconv.ParseFloat(f.Px, 64)
+		size, _ := strconv.ParseFloat(f.Sz, 64)
+		fee, _ := strconv.ParseFloat(f.Fee, 64)
+		side := model.SideSell
+		if f.Side == "B" {
+			side = model.SideBuy
+		}
+		liquidity := "maker"
+		if f.Crossed {
+			liquidity = "taker"
+		}
+		out = append(out, exchange.Fill{
+			VenueOrderID: strconv.FormatInt(f.Oid, 10),
+			TradeID:      strconv.FormatInt(f.Tid, 10),
+			Symbol:       f.Coin,
+			Side:         side,
+			Price:        price,
+			Size:         size,
+			Fee:          fee,
+			Liquidity:    liquidity,
+			Time:         time.UnixMilli(f.Time).UTC(),
+		})
+	}
+	return out, nil
+}
+
+func (c *Client) OpenOrders(ctx context.Context, account string) ([]exchange.OrderState, error) {
+	var resp []struct {
+		Coin   string `json:"coin"`
+		Oid    int64  `json:"oid"`
+		Sz     string `json:"sz"`
+		OrigSz string `json:"origSz"`
+	}
+	if err := c.info(ctx, map[string]any{"type": "frontendOpenOrders", "user": account}, &resp); err != nil {
+		return nil, err
+	}
+	out := make([]exchange.OrderState, 0, len(resp))
+	for _, o := range resp {
+		remaining, _ := strconv.ParseFloat(o.Sz, 64)
+		original, _ := strconv.ParseFloat(o.OrigSz, 64)
+		st := exchange.OrderState{VenueOrderID: strconv.FormatInt(o.Oid, 10), Symbol: o.Coin, Status: model.OrderOpen, FilledSize: math.Max(0, original-remaining)}
+		if st.FilledSize > 0 {
+			st.Status = model.OrderPartiallyFilled
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+func (c *Client) Positions(ctx context.Context, account string) ([]exchange.PositionState, error) {
+	var resp struct {
+		AssetPositions []struct {
+			Position struct {
+				Coin    string  `json:"coin"`
+				Szi     string  `json:"szi"`
+				EntryPx *string `json:"entryPx"`
+			} `json:"position"`
+		} `json:"assetPositions"`
+	}
+	if err := c.info(ctx, map[string]any{"type": "clearinghouseState", "user": account}, &resp); err != nil {
+		return nil, err
+	}
+	out := make([]exchange.PositionState, 0, len(resp.AssetPositions))
+	for _, ap := range resp.AssetPositions {
+		size, _ := strconv.ParseFloat(ap.Position.Szi, 64)
+		if size == 0 {
+			continue
+		}
+		p := exchange.PositionState{Symbol: ap.Position.Coin, Size: size}
+		if ap.Position.EntryPx != nil {
+			p.AvgEntry, _ = strconv.ParseFloat(*ap.Position.EntryPx, 64)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// orderWire builds one order in the exchange's wire format. Market and stop
+// orders are sent as IOC or trigger-market with a limit MarketSlippage
+// through the reference price.
+func (c *Client) orderWire(a asset, isBuy bool, orderType string, price, size float64, reduceOnly bool, tpsl string) omap {
+	limit := price
+	if orderType != model.OrderTypeLimit {
+		if isBuy {
+			limit = price * (1 + c.cfg.MarketSlippage)
+		} else {
+			limit = price * (1 - c.cfg.MarketSlippage)
+		}
+	}
+	var t omap
+	switch {
+	case orderType == model.OrderTypeStop:
+		if tpsl == "" {
+			tpsl = "sl"
+		}
+		t = omap{{"trigger", omap{{"isMarket", true}, {"triggerPx", formatPrice(price, a.szDecimals)}, {"tpsl", tpsl}}}}
+	case orderType == model.OrderTypeMarket:
+		t = omap{{"limit", omap{{"tif", "Ioc"}}}}
+	default:
+		t = omap{{"limit", omap{{"tif", "Gtc"}}}}
+	}
+	return omap{
+		{"a", a.index},
+		{"b", isBuy},
+		{"p", formatPrice(limit, a.szDecimals)},
+		{"s", formatSize(size, a.szDecimals)},
+		{"r", reduceOnly},
+		{"t", t},
+	}
+}
+
+// cloid is the client order id sent with a local order: its id as 16
+// big-endian bytes in hex, the form Hyperliquid requires.
+func cloid(orderID int64) string {
+	return fmt.Sprintf("0x%032x", orderID)
+}
+
+// formatPrice keeps five significant figures and at most 6-szDecimals
+// decimals, the tick rules for perps.
+func formatPrice(px float64, szDecimals int) string {
+	if px >= 100000 {
+		return strconv.FormatFloat(math.Round(px), 'f', -1, 64)
+	}
+	sig, _ := strconv.ParseFloat(strconv.FormatFloat(px, 'g', 5, 64), 64)
+	scale := math.Pow(10, float64(max(0, 6-szDecimals)))
+	return strconv.FormatFloat(math.Round(sig*scale)/scale, 'f', -1, 64)
+}
+
+func formatSize(sz float64, szDecimals int) string {
+	scale := math.Pow(10, float64(szDecimals))
+	return strconv.FormatFloat(math.Floor(sz*scale+1e-9)/scale, 'f', -1, 64)
+}
+
+func (c *Client) asset(ctx context.Context, symbol string) (asset, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.assets == nil {
+		var meta struct {
+			Universe []struct {
+				Name       string `json:"name"`
+				SzDecimals int    `json:"szDecimals"`
+			} `json:"universe"`
+		}
+		if err := c.info(ctx, map[string]any{"type": "meta"}, &meta); err != nil {
+			return asset{}, err
+		}
+		c.assets = make(map[string]asset, len(meta.Universe))
+		for i, u := range meta.Universe {
+			c.assets[u.Name] = asset{index: i, szDecimals: u.SzDecimals}
+		}
+	}
+	a, ok := c.assets[symbol]
+	if !ok {
+		return asset{}, fmt.Errorf("%w: unknown asset %s", exchange.ErrRejected, symbol)
+	}
+	return a, nil
+}
+
+func (c *Client) exchange(ctx context.Context, req exchange.OrderRequest, action omap, out any) error {
+	nonce := uint64(time.Now().UnixMilli())
+	sig, err := signL1Action(req.Signer, action, nonce, c.cfg.Mainnet)
+	if err != nil {
+		return err
+	}
+	body := map[string]any{
+		"action":       jsonAction(action),
+		"nonce":        nonce,
+		"signature":    sig,
+		"vaultAddress": nil,
+	}
+	var resp struct {
+		Status   string          `json:"status"`
+		Response json.RawMessage `json:"response"`
+	}
+	if err := c.post(ctx, "/exchange", body, &resp); err != nil {
+		return err
+	}
+	if resp.Status != "ok" {
+		var msg string
+		if json.Unmarshal(resp.Response, &msg) != nil {
+			msg = string(resp.Response)
+		}
+		return fmt.Errorf("%w: %s", exchange.ErrRejected, msg)
+	}
+	return json.Unmarshal(resp.Response, out)
+}
+
+func (c *Client) info(ctx context.Context, req map[string]any, out any) error {
+	return c.post(ctx, "/info", req, out)
+}
+
+func (c *Client) post(ctx context.Context, path string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.BaseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	res, err := c.http.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("hyperliquid %s: %w", path, err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, 4<<20))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("hyperliquid %s: status %d: %s", path, res.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("hyperliquid %s: decode: %w", path, err)
+	}
+	return nil
+}
+
+// jsonAction renders an omap as JSON with the same key order it was signed in.
+func jsonAction(v any) json.RawMessage {
+	var buf bytes.Buffer
+	writeJSON(&buf, v)
+	return buf.Bytes()
+}
+
+func writeJSON(buf *bytes.Buffer, v any) {
+	switch x := v.(type) {
+	case omap:
+		buf.WriteByte('{')
+		for i, e := range x {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(e.key)
+			buf.Write(key)
+			buf.WriteByte(':')
+			writeJSON(buf, e.value)
+		}
+		buf.WriteByte('}')
+	case []any:
+		buf.WriteByte('[')
+		for i, item := range x {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSON(buf, item)
+		}
+		buf.WriteByte(']')
+	default:
+		b, _ := json.Marshal(x)
+		buf.Write(b)
+	}
+}
@@ -0,0 +1,460 @@
+package hyperliquid
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"autotrade/backend-go/internal/exchange"
+	"autotrade/backend-go/internal/model"
+	"autotrade/backend-go/internal/wallet"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// standIn is a local stand-in for the Hyperliquid HTTP API. /info replies are
+// keyed by request type; /exchange requests are recorded and answered with
+// exchangeReply.
+type standIn struct {
+	t *testing.T
+
+	mu            sync.Mutex
+	info          map[string]string
+	infoRequests  []map[string]any
+	exchangeReply string
+	exchanges     []exchangeRequest
+}
+
+type exchangeRequest struct {
+	Action    omap
+	Nonce     uint64
+	Signature signature
+	raw       map[string]json.RawMessage
+}
+
+func newStandIn(t *testing.T) (*standIn, *Client) {
+	s := &standIn{t: t, info: map[string]string{
+		"meta": `{"universe":[{"name":"BTC","szDecimals":5},{"name":"ETH","szDecimals":4}]}`,
+	}}
+	srv := httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(srv.Close)
+	return s, New(Config{BaseURL: srv.URL + "/", MarketSlippage: 0.05})
+}
+
+func (s *standIn) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	switch r.URL.Path {
+	case "/info":
+		var req map[string]any
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.infoRequests = append(s.infoRequests, req)
+		reply, ok := s.info[fmt.Sprint(req["type"])]
+		if !ok {
+			http.Error(w, "unexpected info request", http.StatusUnprocessableEntity)
+			return
+		}
+		io.WriteString(w, reply)
+	case "/exchange":
+		var req exchangeRequest
+		if err := json.Unmarshal(body, &req.raw); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		action, err := decodeOrdered(req.raw["action"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Action = action.(omap)
+		json.Unmarshal(req.raw["nonce"], &req.Nonce)
+		json.Unmarshal(req.raw["signature"], &req.Signature)
+		s.exchanges = append(s.exchanges, req)
+		io.WriteString(w, s.exchangeReply)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *standIn) lastExchange() exchangeRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.exchanges) == 0 {
+		s.t.Fatal("no exchange request")
+	}
+	return s.exchanges[len(s.exchanges)-1]
+}
+
+func (s *standIn) infoTypes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, 0, len(s.infoRequests))
+	for _, req := range s.infoRequests {
+		out = append(out, fmt.Sprint(req["type"]))
+	}
+	return out
+}
+
+// decodeOrdered parses JSON keeping object key order, so the stand-in can
+// hash the action exactly as the venue does.
+func decodeOrdered(raw []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return decodeValue(dec)
+}
+
+func decodeValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch x := tok.(type) {
+	case json.Delim:
+		if x == '[' {
+			out := []any{}
+			for dec.More() {
+				v, err := decodeValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, v)
+			}
+			_, err := dec.Token()
+			return out, err
+		}
+		out := omap{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, kv{key.(string), v})
+		}
+		_, err := dec.Token()
+		return out, err
+	case json.Number:
+		return x.Int64()
+	default:
+		return x, nil
+	}
+}
+
+// signer recovers the address that signed the request's action.
+func (req exchangeRequest) signer(t *testing.T, mainnet bool) string {
+	t.Helper()
+	connectionID, err := actionHash(req.Action, req.Nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := "b"
+	if mainnet {
+		source = "a"
+	}
+	digest := wallet.Keccak256([]byte{0x19, 0x01}, exchangeDomain, wallet.Keccak256(agentTypeHash, wallet.Keccak256([]byte(source)), connectionID))
+	r, _ := hex.DecodeString(strings.TrimPrefix(req.Signature.R, "0x"))
+	s, _ := hex.DecodeString(strings.TrimPrefix(req.Signature.S, "0x"))
+	compact := append(append([]byte{byte(req.Signature.V)}, r...), s...)
+	pub, _, err := ecdsa.RecoverCompact(compact, digest)
+	if err != nil {
+		t.Fatalf("recover signer: %v", err)
+	}
+	return wallet.PubKeyAddress(pub)
+}
+
+func testSigner(t *testing.T) (*secp256k1.PrivateKey, string) {
+	key, address, err := wallet.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, address
+}
+
+func (m omap) keys() []string {
+	out := make([]string, len(m))
+	for i, e := range m {
+		out[i] = e.key
+	}
+	return out
+}
+
+func field(m omap, key string) any {
+	for _, e := range m {
+		if e.key == key {
+			return e.value
+		}
+	}
+	return nil
+}
+
+func TestPlaceOrderWithBracket(t *testing.T) {
+	s, c := newStandIn(t)
+	s.exchangeReply = `{"status":"ok","response":{"type":"order","data":{"statuses":[{"resting":{"oid":77738308}},"waitingForFill",{"resting":{"oid":77738310}}]}}}`
+	key, address := testSigner(t)
+
+	leg := func(id int64, role, orderType string, price float64) model.Order {
+		return model.Order{ID: id, Symbol: "ETH", Side: model.SideSell, OrderType: orderType, Size: 0.25, EntryPrice: price, Role: role}
+	}
+	placement, err := c.PlaceOrder(context.Background(), exchange.OrderRequest{
+		Account: "0xabc",
+		Signer:  key,
+		Order: model.Order{
+			ID:         42,
+			Symbol:     "ETH",
+			Side:       model.SideBuy,
+			OrderType:  model.OrderTypeLimit,
+			Size:       0.25,
+			EntryPrice: 3000.123,
+			StopLoss:   2900,
+			TakeProfit: 3200,
+			Role:       model.RoleEntry,
+		},
+		Legs: []model.Order{
+			leg(43, model.RoleStopLoss, model.OrderTypeStop, 2900),
+			leg(44, model.RoleTakeProfit, model.OrderTypeLimit, 3200),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if placement.VenueOrderID != "77738308" || placement.Status != model.OrderOpen {
+		t.Fatalf("placement = %+v", placement)
+	}
+	if fmt.Sprint(placement.LegOrderIDs) != "[ 77738310]" {
+		t.Fatalf("leg order ids = %q", placement.LegOrderIDs)
+	}
+
+	req := s.lastExchange()
+	if got := req.signer(t, false); got != address {
+		t.Fatalf("action signed by %s, want agent %s", got, address)
+	}
+	if string(req.raw["vaultAddress"]) != "null" {
+		t.Fatalf("vaultAddress = %s", req.raw["vaultAddress"])
+	}
+	if field(req.Action, "type") != "order" || field(req.Action, "grouping") != "normalTpsl" {
+		t.Fatalf("action = %v", req.Action)
+	}
+	orders := field(req.Action, "orders").([]any)
+	if len(orders) != 3 {
+		t.Fatalf("orders = %v", orders)
+	}
+	entry, sl, tp := orders[0].(omap), orders[1].(omap), orders[2].(omap)
+	if field(entry, "a") != int64(1) || field(entry, "b") != true || field(entry, "p") != "3000.1" || field(entry, "s") != "0.25" || field(entry, "r") != false {
+		t.Fatalf("entry = %v", entry)
+	}
+	if keys := fmt.Sprint(entry.keys()); keys != "[a b p s r t c]" || field(entry, "c") != "0x0000000000000000000000000000002a" {
+		t.Fatalf("entry keys %s, cloid %v", keys, field(entry, "c"))
+	}
+	if field(sl, "c") != "0x0000000000000000000000000000002b" || field(tp, "c") != "0x0000000000000000000000000000002c" {
+		t.Fatalf("bracket legs must carry their own cloids: sl %v, tp %v", field(sl, "c"), field(tp, "c"))
+	}
+	if tif := field(field(field(entry, "t").(omap), "limit").(omap), "tif"); tif != "Gtc" {
+		t.Fatalf("entry tif = %v", tif)
+	}
+	for _, leg := range []struct {
+		wire    omap
+		tpsl    string
+		trigger string
+	}{{sl, "sl", "2900"}, {tp, "tp", "3200"}} {
+		trigger := field(field(leg.wire, "t").(omap), "trigger").(omap)
+		if field(leg.wire, "b") != false || field(leg.wire, "r") != true || field(trigger, "tpsl") != leg.tpsl || field(trigger, "triggerPx") != leg.trigger || field(trigger, "isMarket") != true {
+			t.Fatalf("%s leg = %v", leg.tpsl, leg.wire)
+		}
+	}
+
+	// Asset metadata is fetched once.
+	if _, err := c.PlaceOrder(context.Background(), exchange.OrderRequest{Signer: key, Order: model.Order{Symbol: "BTC", Side: model.SideSell, OrderType: model.OrderTypeLimit, Size: 0.001, EntryPrice: 60000, Role: model.RoleEntry}}); err != nil {
+		t.Fatal(err)
+	}
+	if types := s.infoTypes(); len(types) != 1 || types[0] != "meta" {
+		t.Fatalf("info requests = %v", types)
+	}
+}
+
+func TestPlaceMarketOrderFilled(t *testing.T) {
+	s, c := newStandIn(t)
+	s.exchangeReply = `{"status":"ok","response":{"type":"order","data":{"statuses":[{"filled":{"totalSz":"0.02","avgPx":"1891.4","oid":77747314}}]}}}`
+	key, _ := testSigner(t)
+	c.cfg.Mainnet = true
+
+	placement, err := c.PlaceOrder(context.Background(), exchange.OrderRequest{Signer: key, Order: model.Order{
+		Symbol: "ETH", Side: model.SideSell, OrderType: model.OrderTypeMarket, Size: 0.02, EntryPrice: 1900, Role: model.RoleEntry,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if placement.Status != model.OrderFilled || placement.FilledSize != 0.02 || placement.AvgPrice != 1891.4 || placement.VenueOrderID != "77747314" {
+		t.Fatalf("placement = %+v", placement)
+	}
+	req := s.lastExchange()
+	wire := field(req.Action, "orders").([]any)[0].(omap)
+	// A sell market order is an IOC limit MarketSlippage below the price.
+	if field(wire, "p") != "1805" || field(field(field(wire, "t").(omap), "limit").(omap), "tif") != "Ioc" {
+		t.Fatalf("wire = %v", wire)
+	}
+	if field(req.Action, "grouping") != "na" {
+		t.Fatalf("grouping = %v", field(req.Action, "grouping"))
+	}
+	if _, address := key, wallet.PubKeyAddress(key.PubKey()); req.signer(t, true) != address {
+		t.Fatal("mainnet action not signed with source a")
+	}
+}
+
+func TestPlaceOrderRejected(t *testing.T) {
+	key, _ := testSigner(t)
+	order := exchange.OrderRequest{Signer: key, Order: model.Order{Symbol: "ETH", Side: model.SideBuy, OrderType: model.OrderTypeLimit, Size: 1, EntryPrice: 10, Role: model.RoleEntry}}
+	for name, reply := range map[string]string{
+		"order error":  `{"status":"ok","response":{"type":"order","data":{"statuses":[{"error":"Order must have minimum value of $10."}]}}}`,
+		"action error": `{"status":"err","response":"User or API Wallet does not exist."}`,
+	} {
+		s, c := newStandIn(t)
+		s.exchangeReply = reply
+		if _, err := c.PlaceOrder(context.Background(), order); !errors.Is(err, exchange.ErrRejected) {
+			t.Errorf("%s: err = %v, want ErrRejected", name, err)
+		}
+	}
+
+	_, c := newStandIn(t)
+	unknown := order
+	unknown.Order.Symbol = "DOGE"
+	if _, err := c.PlaceOrder(context.Background(), unknown); !errors.Is(err, exchange.ErrRejected) {
+		t.Errorf("unknown asset: err = %v, want ErrRejected", err)
+	}
+	if _, err := c.PlaceOrder(context.Background(), exchange.OrderRequest{Order: order.Order}); err == nil {
+		t.Error("placing without an agent key must fail")
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	s, c := newStandIn(t)
+	key, address := testSigner(t)
+	s.exchangeReply = `{"status":"ok","response":{"type":"cancel","data":{"statuses":["success"]}}}`
+	req := exchange.OrderRequest{Signer: key, Order: model.Order{Symbol: "BTC", VenueOrderID: "77738308"}}
+	if err := c.CancelOrder(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	sent := s.lastExchange()
+	cancel := field(sent.Action, "cancels").([]any)[0].(omap)
+	if field(sent.Action, "type") != "cancel" || field(cancel, "a") != int64(0) || field(cancel, "o") != int64(77738308) {
+		t.Fatalf("action = %v", sent.Action)
+	}
+	if sent.signer(t, false) != address {
+		t.Fatal("cancel not signed by the agent")
+	}
+
+	s.exchangeReply = `{"status":"ok","response":{"type":"cancel","data":{"statuses":[{"error":"Order was never placed, already canceled, or filled."}]}}}`
+	if err := c.CancelOrder(context.Background(), req); !errors.Is(err, exchange.ErrRejected) {
+		t.Fatalf("err = %v, want ErrRejected", err)
+	}
+	req.Order.VenueOrderID = "abc"
+	if err := c.CancelOrder(context.Background(), req); err == nil {
+		t.Fatal("non-numeric venue order id must fail")
+	}
+}
+
+func TestOrderStatus(t *testing.T) {
+	s, c := newStandIn(t)
+	tests := []struct {
+		reply  string
+		status string
+		filled float64
+	}{
+		{`{"status":"order","order":{"order":{"coin":"ETH","oid":42,"sz":"0.5","origSz":"0.5"},"status":"open"}}`, model.OrderOpen, 0},
+		{`{"status":"order","order":{"order":{"coin":"ETH","oid":42,"sz":"0.2","origSz":"0.5"},"status":"open"}}`, model.OrderPartiallyFilled, 0.3},
+		{`{"status":"order","order":{"order":{"coin":"ETH","oid":42,"sz":"0.0","origSz":"0.5"},"status":"filled"}}`, model.OrderFilled, 0.5},
+		{`{"status":"order","order":{"order":{"coin":"ETH","oid":42,"sz":"0.5","origSz":"0.5"},"status":"marginCanceled"}}`, model.OrderCancelled, 0},
+		{`{"status":"order","order":{"order":{"coin":"ETH","oid":42,"sz":"0.5","origSz":"0.5"},"status":"rejected"}}`, model.OrderRejected, 0},
+	}
+	for _, tt := range tests {
+		s.info["orderStatus"] = tt.reply
+		st, err := c.OrderStatus(context.Background(), "0xabc", "42")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Status != tt.status || st.Symbol != "ETH" || st.VenueOrderID != "42" || st.FilledSize < tt.filled-1e-9 || st.FilledSize > tt.filled+1e-9 {
+			t.Errorf("%s: got %+v", tt.reply, st)
+		}
+	}
+	req := s.infoRequests[len(s.infoRequests)-1]
+	if req["user"] != "0xabc" || req["oid"] != float64(42) {
+		t.Fatalf("request = %v", req)
+	}
+
+	// An order whose placement response was lost is found by its cloid.
+	st, err := c.OrderStatusByClientID(context.Background(), "0xabc", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.VenueOrderID != "42" || st.Status != model.OrderRejected {
+		t.Fatalf("by cloid: got %+v", st)
+	}
+	if req := s.infoRequests[len(s.infoRequests)-1]; req["oid"] != "0x00000000000000000000000000000007" {
+		t.Fatalf("request = %v", req)
+	}
+
+	s.info["orderStatus"] = `{"status":"unknownOid"}`
+	if _, err := c.OrderStatus(context.Background(), "0xabc", "42"); !errors.Is(err, exchange.ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+	if _, err := c.OrderStatusByClientID(context.Background(), "0xabc", 7); !errors.Is(err, exchange.ErrNotFound) {
+		t.Fatalf("by cloid: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFills(t *testing.T) {
+	s, c := newStandIn(t)
+	s.info["userFillsByTime"] = `[
+		{"coin":"ETH","px":"1891.4","sz":"0.02","side":"A","time":1700000000123,"oid":77747314,"tid":118906512037719,"fee":"0.0151","crossed":true},
+		{"coin":"BTC","px":"60000","sz":"0.001","side":"B","time":1700000001000,"oid":5,"tid":6,"fee":"-0.001","crossed":false}
+	]`
+	since := time.UnixMilli(1699990000000)
+	fills, err := c.Fills(context.Background(), "0xabc", since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := s.infoRequests[len(s.infoRequests)-1]
+	if req["user"] != "0xabc" || req["startTime"] != float64(since.UnixMilli()) {
+		t.Fatalf("request = %v", req)
+	}
+	want := []exchange.Fill{
+		{VenueOrderID: "77747314", TradeID: "118906512037719", Symbol: "ETH", Side: model.SideSell, Price: 1891.4, Size: 0.02, Fee: 0.0151, Liquidity: "taker", Time: time.UnixMilli(1700000000123).UTC()},
+		{VenueOrderID: "5", TradeID: "6", Symbol: "BTC", Side: model.SideBuy, Price: 60000, Size: 0.001, Fee: -0.001, Liquidity: "maker", Time: time.UnixMilli(1700000001000).UTC()},
+	}
+	if len(fills) != len(want) {
+		t.Fatalf("fills = %+v", fills)
+	}
+	for i := range want {
+		if fills[i] != want[i] {
+			t.Errorf("fill %d = %+v, want %+v", i, fills[i], want[i])
+		}
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	_, c := newStandIn(t)
+	// The stand-in answers unknown info types with 422.
+	if _, err := c.OpenOrders(context.Background(), "0xabc"); err == nil || !strings.Contains(err.Error(), "status 422") {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package hyperliquid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// omap is a msgpack map that keeps insertion order. Hyperliquid hashes the
+// msgpack bytes of an action, so key order must match its reference SDK.
+type omap []kv
+
+type kv struct {
+	key   string
+	value any
+}
+
+func packMsg(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := pack(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func pack(buf *bytes.Buffer, v any) error {
+	switch x := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if x {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case int:
+		packUint(buf, uint64(x))
+	case int64:
+		packUint(buf, uint64(x))
+	case uint64:
+		packUint(buf, x)
+	case string:
+		packLen(buf, len(x), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		buf.WriteString(x)
+	case []any:
+		packLen(buf, len(x), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, item := range x {
+			if err := pack(buf, item); err != nil {
+				return err
+			}
+		}
+	case omap:
+		packLen(buf, len(x), 0x80, 15, 0, 0xde, 0xdf)
+		for _, e := range x {
+			if err := pack(buf, e.key); err != nil {
+				return err
+			}
+			if err := pack(buf, e.value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+	return nil
+}
+
+// packUint uses the smallest encoding, as msgpack-python does. Negative
+// values never appear in exchange actions.
+func packUint(buf *bytes.Buffer, n uint64) {
+	switch {
+	case n < 128:
+		buf.WriteByte(byte(n))
+	case n <= 0xff:
+		buf.Write([]byte{0xcc, byte(n)})
+	case n <= 0xffff:
+		buf.WriteByte(0xcd)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n <= 0xffffffff:
+		buf.WriteByte(0xce)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		buf.WriteByte(0xcf)
+		buf.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+}
+
+// packLen writes a container or string header: the fix form up to fixMax,
+// then the 8-bit (if the type has one), 16-bit and 32-bit length forms.
+func packLen(buf *bytes.Buffer, n int, fix byte, fixMax int, op8, op16, op32 byte) {
+	switch {
+	case n <= fixMax:
+		buf.WriteByte(fix | byte(n))
+	case op8 != 0 && n <= 0xff:
+		buf.Write([]byte{op8, byte(n)})
+	case n <= 0xffff:
+		buf.WriteByte(op16)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(op32)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
@@ -0,0 +1,68 @@
+package hyperliquid
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+
+	"autotrade/backend-go/internal/wallet"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+type signature struct {
+	R string `json:"r"`
+	S string `json:"s"`
+	V int    `json:"v"`
+}
+
+var (
+	domainTypeHash = wallet.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+	agentTypeHash  = wallet.Keccak256([]byte("Agent(string source,bytes32 connectionId)"))
+	// L1 actions are signed against a fixed domain: chain 1337, zero verifying contract.
+	exchangeDomain = wallet.Keccak256(
+		domainTypeHash,
+		wallet.Keccak256([]byte("Exchange")),
+		wallet.Keccak256([]byte("1")),
+		uint256(1337),
+		make([]byte, 32),
+	)
+)
+
+func uint256(n uint64) []byte {
+	out := make([]byte, 32)
+	binary.BigEndian.PutUint64(out[24:], n)
+	return out
+}
+
+// actionHash is the connection ID Hyperliquid recomputes from the request:
+// keccak(msgpack(action) || nonce || vault flag). Vault trading is not used.
+func actionHash(action omap, nonce uint64) ([]byte, error) {
+	packed, err := packMsg(action)
+	if err != nil {
+		return nil, err
+	}
+	return wallet.Keccak256(packed, binary.BigEndian.AppendUint64(nil, nonce), []byte{0}), nil
+}
+
+// signL1Action signs an exchange action as a phantom Agent, source "a" on
+// mainnet and "b" on testnet.
+func signL1Action(key *secp256k1.PrivateKey, action omap, nonce uint64, mainnet bool) (signature, error) {
+	connectionID, err := actionHash(action, nonce)
+	if err != nil {
+		return signature{}, err
+	}
+	source := "b"
+	if mainnet {
+		source = "a"
+	}
+	structHash := wallet.Keccak256(agentTypeHash, wallet.Keccak256([]byte(source)), connectionID)
+	digest := wallet.Keccak256([]byte{0x19, 0x01}, exchangeDomain, structHash)
+
+	// SignCompact returns v || r || s with v already offset by 27.
+	compact := ecdsa.SignCompact(key, digest, false)
+	return signature{
+		R: "0x" + hex.EncodeToString(compact[1:33]),
+		S: "0x" + hex.EncodeToString(compact[33:65]),
+		V: int(compact[0]),
+	}, nil
+}
@@ -0,0 +1,91 @@
+package hyperliquid
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// The vectors below are from the Hyperliquid Python SDK's signing tests.
+
+func TestActionHashMatchesReference(t *testing.T) {
+	wire := omap{
+		{"a", 4},
+		{"b", true},
+		{"p", "1670.1"},
+		{"s", "0.0147"},
+		{"r", false},
+		{"t", omap{{"limit", omap{{"tif", "Ioc"}}}}},
+	}
+	action := omap{{"type", "order"}, {"orders", []any{wire}}, {"grouping", "na"}}
+	hash, err := actionHash(action, 1677777606040)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hex.EncodeToString(hash), "0fcbeda5ae3c4950a548021552a4fea2226858c4453571bf3f24ba017eac2908"; got != want {
+		t.Fatalf("connection id = %s, want %s", got, want)
+	}
+}
+
+func TestSignL1ActionMatchesReference(t *testing.T) {
+	raw, _ := hex.DecodeString("0123456789012345678901234567890123456789012345678901234567890123")
+	key := secp256k1.PrivKeyFromBytes(raw)
+	// {"type": "dummy", "num": float_to_int_for_hashing(1000)}
+	action := omap{{"type", "dummy"}, {"num", uint64(100000000000)}}
+	tests := []struct {
+		mainnet bool
+		r, s    string
+		v       int
+	}{
+		{true, "0x53749d5b30552aeb2fca34b530185976545bb22d0b3ce6f62e31be961a59298", "0x755c40ba9bf05223521753995abb2f73ab3229be8ec921f350cb447e384d8ed8", 27},
+		{false, "0x542af61ef1f429707e3c76c5293c80d01f74ef853e34b76efffcb57e574f9510", "0x17b8b32f086e8cdede991f1e2c529f5dd5297cbe8128500e00cbaf766204a613", 28},
+	}
+	for _, tt := range tests {
+		sig, err := signL1Action(key, action, 0, tt.mainnet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The SDK prints r and s as integers, without leading zeros.
+		if trimHex(sig.R) != tt.r || trimHex(sig.S) != tt.s || sig.V != tt.v {
+			t.Errorf("mainnet=%v: got %+v, want r=%s s=%s v=%d", tt.mainnet, sig, tt.r, tt.s, tt.v)
+		}
+	}
+}
+
+func trimHex(s string) string {
+	return "0x" + strings.TrimLeft(strings.TrimPrefix(s, "0x"), "0")
+}
+
+func TestPackMsg(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{"nil", nil, "c0"},
+		{"bools", []any{true, false}, "92c3c2"},
+		{"fixint", 127, "7f"},
+		{"uint8", 128, "cc80"},
+		{"uint16", 0xffff, "cdffff"},
+		{"uint32", 0x10000, "ce00010000"},
+		{"uint64", uint64(100000000000), "cf000000174876e800"},
+		{"fixstr", "abc", "a3616263"},
+		{"str8", strings.Repeat("a", 32), "d920" + strings.Repeat("61", 32)},
+		{"map keeps order", omap{{"b", 1}, {"a", 2}}, "82a16201a16102"},
+		{"array16", make([]any, 16), "dc0010" + strings.Repeat("c0", 16)},
+	}
+	for _, tt := range tests {
+		got, err := packMsg(tt.in)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if hex.EncodeToString(got) != tt.want {
+			t.Errorf("%s: got %x, want %s", tt.name, got, tt.want)
+		}
+	}
+	if _, err := packMsg(1.5); err == nil {
+		t.Error("floats must be rejected; prices and sizes are strings on the wire")
+	}
+}
@@ -1,23 +1,115 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
+// AccountState is the ledger cash balance marked to market through the open
+// positions.
 type AccountState struct {
-	Equity    float64   `json:"equity"`
-	Leverage  float64   `json:"leverage"`
-	OpenPnL   float64   `json:"openPnl"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	Balance     float64   `json:"balance"`
+	RealizedPnL float64   `json:"realizedPnl"`
+	OpenPnL     float64   `json:"openPnl"`
+	Equity      float64   `json:"equity"`
+	Notional    float64   `json:"notional"`
+	UsedMargin  float64   `json:"usedMargin"`
+	FreeMargin  float64   `json:"freeMargin"`
+	Leverage    float64   `json:"leverage"`
+	StaleMarks  []string  `json:"staleMarks"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+const (
+	LedgerDeposit     = "deposit"
+	LedgerWithdrawal  = "withdrawal"
+	LedgerRealisedPnL = "realised_pnl"
+	LedgerFee         = "fee"
+	LedgerFunding     = "funding"
+	LedgerAdjustment  = "adjustment"
+)
+
+// LedgerEntry is one signed change to cash; Balance is the running total
+// after it, computed over the account's whole history in the same execution.
+// Paper and live cash are separate ledgers.
+type LedgerEntry struct {
+	ID        int64     `json:"id"`
+	Execution string    `json:"execution"`
+	Kind      string    `json:"kind"`
+	Amount    float64   `json:"amount"`
+	Balance   float64   `json:"balance"`
+	Symbol    string    `json:"symbol"`
+	RefType   string    `json:"refType"`
+	RefID     *int64    `json:"refId"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type LedgerQuery struct {
+	Execution string
+	From      *time.Time
+	To        *time.Time
+	Kind      string
+	Limit     int
+}
+
+type FundingPayment struct {
+	ID        int64     `json:"id"`
+	Symbol    string    `json:"symbol"`
+	Period    time.Time `json:"period"`
+	Side      string    `json:"side"`
+	Size      float64   `json:"size"`
+	MarkPrice float64   `json:"markPrice"`
+	Rate      float64   `json:"rate"`
+	Amount    float64   `json:"amount"`
+}
+
+type Mark struct {
+	Symbol     string    `json:"symbol"`
+	Price      float64   `json:"price"`
+	CapturedAt time.Time `json:"capturedAt"`
 }
 
 type Fill struct {
-	ID          int64     `json:"id"`
-	Symbol      string    `json:"symbol"`
-	Side        string    `json:"side"`
-	Price       float64   `json:"price"`
-	Size        float64   `json:"size"`
-	RealizedPnL float64   `json:"realizedPnl"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"createdAt"`
+	ID           int64     `json:"id"`
+	OrderID      *int64    `json:"orderId"`
+	Symbol       string    `json:"symbol"`
+	Side         string    `json:"side"`
+	Price        float64   `json:"price"`
+	Size         float64   `json:"size"`
+	RealizedPnL  float64   `json:"realizedPnl"`
+	Fee          float64   `json:"fee"`
+	Slippage     float64   `json:"slippage"`
+	Liquidity    string    `json:"liquidity"`
+	Status       string    `json:"status"`
+	VenueTradeID string    `json:"venueTradeId"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Position is the net position in one symbol. Paper and live fills are
+// netted separately; Execution says which book it belongs to.
+type Position struct {
+	Execution         string     `json:"execution"`
+	Symbol            string     `json:"symbol"`
+	Side              string     `json:"side"`
+	Size              float64    `json:"size"`
+	AvgEntry          float64    `json:"avgEntry"`
+	RealizedPnL       float64    `json:"realizedPnl"`
+	CumulativeFunding float64    `json:"cumulativeFunding"` // received (+) or paid (-) since opened
+	OpenedAt          *time.Time `json:"openedAt"`
+	UpdatedAt         time.Time  `json:"updatedAt"`
+
+	// Mark fields are filled in by the service from market_snapshots.
+	MarkPrice     float64    `json:"markPrice"`
+	MarkedAt      *time.Time `json:"markedAt"`
+	MarkAgeSecs   float64    `json:"markAgeSecs"`
+	MarkStale     bool       `json:"markStale"`
+	UnrealizedPnL float64    `json:"unrealizedPnl"`
+}
+
+type AccountPosition struct {
+	Account string
+	Position
 }
 
 type ReviewInput struct {
@@ -37,11 +129,31 @@ type StrategyDerive struct {
 }
 
 type WalletSession struct {
-	Address       string    `json:"address"`
-	Connected     bool      `json:"connected"`
-	AgentApproved bool      `json:"agentApproved"`
-	AgentPubKey   string    `json:"agentPubKey"`
-	UpdatedAt     time.Time `json:"updatedAt"`
+	Address         string     `json:"address"`
+	Connected       bool       `json:"connected"`
+	AgentApproved   bool       `json:"agentApproved"`
+	AgentPubKey     string     `json:"agentPubKey"`
+	AgentValidUntil *time.Time `json:"agentValidUntil"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+type WalletNonce struct {
+	Nonce     string    `json:"nonce"`
+	Domain    string    `json:"domain"`
+	ChainID   int64     `json:"chainId"`
+	IssuedAt  time.Time `json:"issuedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type SessionToken struct {
+	Token     string    `json:"token"`
+	Address   string    `json:"address"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type AgentApproval struct {
+	AgentAddress string    `json:"agentAddress"`
+	ValidUntil   time.Time `json:"validUntil"`
 }
 
 type StrategyStatus struct {
@@ -58,6 +170,7 @@ type OrderInput struct {
 	Side       string  `json:"side"`
 	OrderType  string  `json:"orderType"`
 	Size       float64 `json:"size"`
+	RiskPct    float64 `json:"riskPct,omitempty"`
 	EntryPrice float64 `json:"entryPrice"`
 	StopLoss   float64 `json:"stopLoss"`
 	TakeProfit float64 `json:"takeProfit"`
@@ -65,16 +178,175 @@ type OrderInput struct {
 	Execution  string  `json:"execution"`
 }
 
+type SizeInput struct {
+	Execution  string  `json:"execution"`
+	Symbol     string  `json:"symbol"`
+	Side       string  `json:"side"`
+	EntryPrice float64 `json:"entryPrice"`
+	StopLoss   float64 `json:"stopLoss"`
+	RiskPct    float64 `json:"riskPct"`
+	RiskAmount float64 `json:"riskAmount"`
+}
+
+type SizeQuote struct {
+	Symbol     string  `json:"symbol"`
+	Side       string  `json:"side"`
+	Size       float64 `json:"size"`
+	LotSize    float64 `json:"lotSize"`
+	RiskAmount float64 `json:"riskAmount"`
+	Notional   float64 `json:"notional"`
+	Leverage   float64 `json:"leverage"`
+	LossAtStop float64 `json:"lossAtStop"`
+	Equity     float64 `json:"equity"`
+}
+
+const (
+	SideBuy  = "Buy"
+	SideSell = "Sell"
+)
+
+const (
+	OrderPending         = "pending"
+	OrderOpen            = "open"
+	OrderPartiallyFilled = "partially_filled"
+	OrderFilled          = "filled"
+	OrderCancelled       = "cancelled"
+	OrderRejected        = "rejected"
+	OrderExpired         = "expired"
+)
+
+const (
+	ExecutionPaper = "paper"
+	ExecutionLive  = "live"
+	// ExecutionPaperAuto orders are written by the Python auto-trader as
+	// pending and admitted by the paper matcher.
+	ExecutionPaperAuto = "paper-auto"
+)
+
+// BookExecutions lists the executions whose orders trade against book: paper
+// positions are shared by manual and auto-trader orders.
+func BookExecutions(book string) []string {
+	if book == ExecutionPaper {
+		return []string{ExecutionPaper, ExecutionPaperAuto}
+	}
+	return []string{book}
+}
+
+const (
+	OrderTypeMarket = "market"
+	OrderTypeLimit  = "limit"
+	OrderTypeStop   = "stop"
+)
+
+// Order roles: an entry order's stop-loss and take-profit become child orders
+// once it fills, and the first child to fill cancels the other.
+const (
+	RoleEntry      = "entry"
+	RoleStopLoss   = "stop_loss"
+	RoleTakeProfit = "take_profit"
+)
+
 type Order struct {
+	ID           int64     `json:"id"`
+	Symbol       string    `json:"symbol"`
+	Side         string    `json:"side"`
+	OrderType    string    `json:"orderType"`
+	Size         float64   `json:"size"`
+	FilledSize   float64   `json:"filledSize"`
+	EntryPrice   float64   `json:"entryPrice"`
+	StopLoss     float64   `json:"stopLoss"`
+	TakeProfit   float64   `json:"takeProfit"`
+	Status       string    `json:"status"`
+	Execution    string    `json:"execution"`
+	ParentID     *int64    `json:"parentId"`
+	Role         string    `json:"role"`
+	VenueOrderID string    `json:"venueOrderId"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// AccountOrder is an order with its owner, for jobs that work across accounts.
+type AccountOrder struct {
+	Account string
+	Order
+}
+
+type Candle struct {
 	ID         int64     `json:"id"`
 	Symbol     string    `json:"symbol"`
-	Side       string    `json:"side"`
-	OrderType  string    `json:"orderType"`
-	Size       float64   `json:"size"`
-	EntryPrice float64   `json:"entryPrice"`
-	StopLoss   float64   `json:"stopLoss"`
-	TakeProfit float64   `json:"takeProfit"`
-	Status     string    `json:"status"`
-	Execution  string    `json:"execution"`
-	CreatedAt  time.Time `json:"createdAt"`
+	Open       float64   `json:"open"`
+	High       float64   `json:"high"`
+	Low        float64   `json:"low"`
+	Close      float64   `json:"close"`
+	Volume     float64   `json:"volume"`
+	Volatility float64   `json:"volatility"`
+	CapturedAt time.Time `json:"capturedAt"`
+}
+
+type IdempotencyKey struct {
+	Key         string
+	RequestHash string
+	OrderID     *int64
+	CreatedAt   time.Time
+}
+
+type OrderAmend struct {
+	EntryPrice *float64 `json:"entryPrice"`
+	Size       *float64 `json:"size"`
+	StopLoss   *float64 `json:"stopLoss"`
+	TakeProfit *float64 `json:"takeProfit"`
+}
+
+type OrderEvent struct {
+	ID         int64          `json:"id"`
+	OrderID    int64          `json:"orderId"`
+	Event      string         `json:"event"`
+	FromStatus string         `json:"fromStatus"`
+	ToStatus   string         `json:"toStatus"`
+	Detail     map[string]any `json:"detail"`
+	CreatedAt  time.Time      `json:"createdAt"`
+}
+
+// ReconciliationReport is one reconciler pass over an account's live orders,
+// fills and positions against the venue.
+type ReconciliationReport struct {
+	ID                 int64           `json:"id"`
+	Account            string          `json:"account"`
+	Execution          string          `json:"execution"`
+	OrdersChecked      int             `json:"ordersChecked"`
+	OrdersUpdated      int             `json:"ordersUpdated"`
+	OrdersOrphaned     []int64         `json:"ordersOrphaned"`
+	FillsRepaired      []string        `json:"fillsRepaired"`
+	UnknownVenueOrders []string        `json:"unknownVenueOrders"`
+	PositionDrift      []PositionDrift `json:"positionDrift"`
+	Error              string          `json:"error"`
+	StartedAt          time.Time       `json:"startedAt"`
+	FinishedAt         time.Time       `json:"finishedAt"`
+}
+
+// PositionDrift is a symbol whose signed local and venue sizes disagree.
+type PositionDrift struct {
+	Symbol    string  `json:"symbol"`
+	LocalSize float64 `json:"localSize"`
+	VenueSize float64 `json:"venueSize"`
+}
+
+// Change is a row change announced on the autotrade_changes channel.
+type Change struct {
+	Table     string    `json:"table"`
+	Op        string    `json:"op"`
+	ID        int64     `json:"id"`
+	Account   string    `json:"account"`
+	Symbol    string    `json:"symbol"`
+	Timeframe string    `json:"timeframe"`
+	At        time.Time `json:"at"`
+}
+
+// StreamEvent is a sequenced account event kept for WebSocket replay.
+type StreamEvent struct {
+	Account   string
+	Seq       int64
+	Topic     string
+	Data      json.RawMessage
+	CreatedAt time.Time
 }
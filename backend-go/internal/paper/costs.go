@@ -0,0 +1,91 @@
+package paper
+
+import (
+	"fmt"
+	"math"
+
+	"autotrade/backend-go/internal/model"
+)
+
+type Fees struct {
+	MakerBps float64
+	TakerBps float64
+}
+
+// Slippage returns how far, in bps, a taker fill of size lands beyond the
+// reference price given the candle it executes in.
+type Slippage interface {
+	Bps(size float64, c model.Candle) float64
+}
+
+type FixedSlippage struct{ BpsValue float64 }
+
+func (f FixedSlippage) Bps(float64, model.Candle) float64 { return f.BpsValue }
+
+// VolatilitySlippage scales with exec_context.volatility (a fraction, 0.016 = 1.6%).
+type VolatilitySlippage struct{ Multiplier float64 }
+
+func (v VolatilitySlippage) Bps(_ float64, c model.Candle) float64 {
+	return v.Multiplier * c.Volatility * 10000
+}
+
+// ImpactSlippage grows linearly with the order's share of candle volume.
+type ImpactSlippage struct{ Coefficient float64 }
+
+func (m ImpactSlippage) Bps(size float64, c model.Candle) float64 {
+	if c.Volume <= 0 {
+		return 0
+	}
+	return m.Coefficient * size / c.Volume * 10000
+}
+
+// NewSlippage builds the named model: fixed, volatility or impact.
+func NewSlippage(name string, fixedBps, volMultiplier, impactCoefficient float64) (Slippage, error) {
+	switch name {
+	case "", "fixed":
+		return FixedSlippage{BpsValue: fixedBps}, nil
+	case "volatility":
+		return VolatilitySlippage{Multiplier: volMultiplier}, nil
+	case "impact":
+		return ImpactSlippage{Coefficient: impactCoefficient}, nil
+	}
+	return nil, fmt.Errorf("unknown slippage model %q", name)
+}
+
+type Costs struct {
+	Fees        map[string]Fees
+	DefaultFees Fees
+	Slippage    Slippage
+}
+
+type Execution struct {
+	Price     float64
+	Fee       float64
+	Slippage  float64 // cost versus the reference price, in quote currency
+	Liquidity string
+}
+
+// Apply prices a fill. Taker fills slip against the trader and pay the taker
+// rate; maker fills execute at the reference price and pay the maker rate.
+func (c Costs) Apply(symbol, side string, taker bool, price, size float64, candle model.Candle) Execution {
+	fees, ok := c.Fees[symbol]
+	if !ok {
+		fees = c.DefaultFees
+	}
+	out := Execution{Price: price, Liquidity: "maker"}
+	rate := fees.MakerBps
+	if taker {
+		out.Liquidity, rate = "taker", fees.TakerBps
+		if c.Slippage != nil {
+			bps := math.Max(0, c.Slippage.Bps(size, candle))
+			if side == model.SideBuy {
+				out.Price = price * (1 + bps/10000)
+			} else {
+				out.Price = price * (1 - bps/10000)
+			}
+		}
+	}
+	out.Slippage = math.Abs(out.Price-price) * size
+	out.Fee = out.Price * size * rate / 10000
+	return out
+}
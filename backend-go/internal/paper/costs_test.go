@@ -0,0 +1,68 @@
+package paper
+
+import (
+	"math"
+	"testing"
+
+	"autotrade/backend-go/internal/model"
+)
+
+func TestNewSlippage(t *testing.T) {
+	c := model.Candle{Volume: 50, Volatility: 0.02}
+	tests := []struct {
+		name string
+		bps  float64
+	}{
+		{"", 3},
+		{"fixed", 3},
+		{"volatility", 0.5 * 0.02 * 10000},
+		{"impact", 0.2 * 5 / 50 * 10000},
+	}
+	for _, tt := range tests {
+		s, err := NewSlippage(tt.name, 3, 0.5, 0.2)
+		if err != nil {
+			t.Fatalf("NewSlippage(%q): %v", tt.name, err)
+		}
+		if got := s.Bps(5, c); math.Abs(got-tt.bps) > 1e-9 {
+			t.Errorf("%q Bps() = %v, want %v", tt.name, got, tt.bps)
+		}
+	}
+	if got := (ImpactSlippage{Coefficient: 0.2}).Bps(5, model.Candle{}); got != 0 {
+		t.Errorf("impact without volume = %v, want 0", got)
+	}
+	if _, err := NewSlippage("random", 0, 0, 0); err == nil {
+		t.Error("NewSlippage accepted an unknown model")
+	}
+}
+
+func TestCostsApply(t *testing.T) {
+	costs := Costs{
+		Fees:        map[string]Fees{"ETH": {MakerBps: 0, TakerBps: 3}},
+		DefaultFees: Fees{MakerBps: 1, TakerBps: 5},
+		Slippage:    FixedSlippage{BpsValue: 10},
+	}
+	tests := []struct {
+		name   string
+		symbol string
+		side   string
+		taker  bool
+		want   Execution
+	}{
+		{"maker at reference", "BTC", model.SideBuy, false, Execution{Price: 100, Fee: 0.02, Liquidity: "maker"}},
+		{"taker buy slips up", "BTC", model.SideBuy, true, Execution{Price: 100.1, Fee: 0.1001, Slippage: 0.2, Liquidity: "taker"}},
+		{"taker sell slips down", "BTC", model.SideSell, true, Execution{Price: 99.9, Fee: 0.0999, Slippage: 0.2, Liquidity: "taker"}},
+		{"symbol schedule", "ETH", model.SideBuy, true, Execution{Price: 100.1, Fee: 0.06006, Slippage: 0.2, Liquidity: "taker"}},
+		{"symbol maker fee", "ETH", model.SideSell, false, Execution{Price: 100, Liquidity: "maker"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := costs.Apply(tt.symbol, tt.side, tt.taker, 100, 2, model.Candle{})
+			if got.Liquidity != tt.want.Liquidity ||
+				math.Abs(got.Price-tt.want.Price) > 1e-9 ||
+				math.Abs(got.Fee-tt.want.Fee) > 1e-9 ||
+				math.Abs(got.Slippage-tt.want.Slippage) > 1e-9 {
+				t.Errorf("Apply() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
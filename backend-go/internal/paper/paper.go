@@ -0,0 +1,77 @@
+// Package paper decides when simulated orders fill against market candles.
+package paper
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"autotrade/backend-go/internal/model"
+)
+
+// ParseTimeframe returns the length of a candle interval such as 1m, 4h or 1d.
+func ParseTimeframe(tf string) (time.Duration, error) {
+	if n, ok := strings.CutSuffix(tf, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid timeframe %q", tf)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(tf)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid timeframe %q", tf)
+	}
+	return d, nil
+}
+
+// Match reports whether a resting order fills on candle c and at what price.
+// c is a snapshot of the period-long candle in progress at c.CapturedAt; its
+// range may include trading from before the order existed, so candles that
+// opened before the order was created never fill it.
+// Limits fill when the candle trades through their price, stops trigger when
+// it touches theirs; if the candle opens beyond the price the fill takes the
+// open, as a gap would on a real venue. Market orders take the open.
+func Match(o model.Order, c model.Candle, period time.Duration) (float64, bool) {
+	if c.CapturedAt.Truncate(period).Before(o.CreatedAt) {
+		return 0, false
+	}
+	price, open := o.EntryPrice, c.Open
+	if open <= 0 {
+		open = price
+	}
+	switch o.OrderType {
+	case model.OrderTypeMarket:
+		return open, open > 0
+	case model.OrderTypeLimit:
+		if o.Side == model.SideBuy && c.Low <= price {
+			return math.Min(price, open), true
+		}
+		if o.Side == model.SideSell && c.High >= price {
+			return math.Max(price, open), true
+		}
+	case model.OrderTypeStop:
+		if o.Side == model.SideBuy && c.High >= price {
+			return math.Max(price, open), true
+		}
+		if o.Side == model.SideSell && c.Low <= price {
+			return math.Min(price, open), true
+		}
+	}
+	return 0, false
+}
+
+// Priority orders fills within one candle. Without intrabar data we assume
+// the worst case, so stops run before take-profit limits.
+func Priority(o model.Order) int {
+	switch o.OrderType {
+	case model.OrderTypeMarket:
+		return 0
+	case model.OrderTypeStop:
+		return 1
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,105 @@
+package paper
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"autotrade/backend-go/internal/model"
+)
+
+func TestParseTimeframe(t *testing.T) {
+	tests := []struct {
+		tf   string
+		want time.Duration
+		ok   bool
+	}{
+		{"1m", time.Minute, true},
+		{"15m", 15 * time.Minute, true},
+		{"4h", 4 * time.Hour, true},
+		{"1d", 24 * time.Hour, true},
+		{"", 0, false},
+		{"0m", 0, false},
+		{"xd", 0, false},
+		{"1M", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimeframe(tt.tf)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseTimeframe(%q) = %v, %v; want %v, ok %v", tt.tf, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 30, 0, time.UTC)
+	next := created.Truncate(time.Minute).Add(time.Minute + 20*time.Second)
+	candle := func(open, high, low float64) model.Candle {
+		return model.Candle{Open: open, High: high, Low: low, Close: open, CapturedAt: next}
+	}
+	order := func(side, orderType string, price float64) model.Order {
+		return model.Order{Side: side, OrderType: orderType, EntryPrice: price, CreatedAt: created}
+	}
+	tests := []struct {
+		name  string
+		order model.Order
+		c     model.Candle
+		price float64
+		ok    bool
+	}{
+		{"market takes open", order(model.SideBuy, model.OrderTypeMarket, 100), candle(101, 102, 99), 101, true},
+		{"market without open", order(model.SideBuy, model.OrderTypeMarket, 100), candle(0, 102, 99), 100, true},
+		{"buy limit touched", order(model.SideBuy, model.OrderTypeLimit, 100), candle(101, 102, 100), 100, true},
+		{"buy limit not reached", order(model.SideBuy, model.OrderTypeLimit, 100), candle(101, 102, 100.5), 0, false},
+		{"buy limit gaps down", order(model.SideBuy, model.OrderTypeLimit, 100), candle(98, 99, 97), 98, true},
+		{"sell limit touched", order(model.SideSell, model.OrderTypeLimit, 100), candle(99, 100, 98), 100, true},
+		{"sell limit gaps up", order(model.SideSell, model.OrderTypeLimit, 100), candle(102, 103, 101), 102, true},
+		{"buy stop triggered", order(model.SideBuy, model.OrderTypeStop, 100), candle(99, 100, 98), 100, true},
+		{"buy stop gaps up", order(model.SideBuy, model.OrderTypeStop, 100), candle(102, 103, 101), 102, true},
+		{"sell stop triggered", order(model.SideSell, model.OrderTypeStop, 100), candle(101, 102, 100), 100, true},
+		{"sell stop gaps down", order(model.SideSell, model.OrderTypeStop, 100), candle(98, 99, 97), 98, true},
+		{"sell stop not reached", order(model.SideSell, model.OrderTypeStop, 100), candle(101, 102, 100.5), 0, false},
+		{
+			name:  "candle in progress at creation",
+			order: order(model.SideBuy, model.OrderTypeLimit, 100),
+			c:     model.Candle{Open: 101, High: 102, Low: 99, CapturedAt: created.Add(10 * time.Second)},
+		},
+		{
+			name:  "candle opening at creation",
+			order: model.Order{Side: model.SideBuy, OrderType: model.OrderTypeLimit, EntryPrice: 100, CreatedAt: created.Truncate(time.Minute)},
+			c:     model.Candle{Open: 101, High: 102, Low: 99, CapturedAt: created},
+			price: 100,
+			ok:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, ok := Match(tt.order, tt.c, time.Minute)
+			if price != tt.price || ok != tt.ok {
+				t.Errorf("Match() = %v, %v; want %v, %v", price, ok, tt.price, tt.ok)
+			}
+		})
+	}
+}
+
+// A candle touching both legs of a long bracket must fill the stop first.
+func TestPriorityStopBeforeTakeProfit(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	orders := []model.Order{
+		{Role: model.RoleTakeProfit, Side: model.SideSell, OrderType: model.OrderTypeLimit, EntryPrice: 110, CreatedAt: created},
+		{Role: model.RoleStopLoss, Side: model.SideSell, OrderType: model.OrderTypeStop, EntryPrice: 95, CreatedAt: created},
+	}
+	c := model.Candle{Open: 100, High: 111, Low: 94, CapturedAt: created.Add(time.Minute)}
+	sort.SliceStable(orders, func(i, j int) bool { return Priority(orders[i]) < Priority(orders[j]) })
+	for _, o := range orders {
+		if _, ok := Match(o, c, time.Minute); !ok {
+			t.Fatalf("%s leg did not match", o.Role)
+		}
+	}
+	if orders[0].Role != model.RoleStopLoss {
+		t.Errorf("first leg = %s, want %s", orders[0].Role, model.RoleStopLoss)
+	}
+	if Priority(model.Order{OrderType: model.OrderTypeMarket}) >= Priority(orders[0]) {
+		t.Error("market orders should run before stops")
+	}
+}
@@ -0,0 +1,366 @@
+// Package realtime fans events out to WebSocket clients by topic.
+package realtime
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"log"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Account-scoped topics; market data uses market.<SYMBOL>.<timeframe>.
+const (
+	TopicOrders         = "orders"
+	TopicFills          = "fills"
+	TopicPositions      = "positions"
+	TopicStrategyStatus = "strategy.status"
+)
+
+var marketTopic = regexp.MustCompile(`^market\.[A-Z0-9]+\.[0-9]+[smhdw]$`)
+
+func ValidTopic(topic string) bool {
+	switch topic {
+	case TopicOrders, TopicFills, TopicPositions, TopicStrategyStatus:
+		return true
+	}
+	return marketTopic.MatchString(topic)
+}
+
+func MarketTopic(symbol, timeframe string) string {
+	return "market." + symbol + "." + timeframe
+}
+
+// Event is one published change. Account events carry Seq, increasing per
+// account, so reconnecting clients can resume; market events have none.
+type Event struct {
+	Seq     int64     `json:"seq,omitempty"`
+	Topic   string    `json:"topic"`
+	Account string    `json:"account,omitempty"`
+	Data    any       `json:"data"`
+	At      time.Time `json:"at"`
+}
+
+// Store holds sequenced events beyond the in-memory replay buffer. Append
+// saves an account event under the account's next sequence number and
+// returns it with Seq and At set.
+type Store interface {
+	Append(ctx context.Context, e Event) (Event, error)
+	LatestSeq(ctx context.Context, account string) (int64, error)
+	EventsAfter(ctx context.Context, account string, after int64, limit int) ([]Event, error)
+}
+
+// Hub tracks connected clients and their subscriptions, and keeps the last
+// replay events of each account for resuming clients. Publish never blocks:
+// a client whose send buffer is full is disconnected.
+type Hub struct {
+	sendBuffer int
+	replay     int
+	store      Store
+
+	// accounts holds a *sync.Mutex per account, keeping its events
+	// sequenced and published in order, and ordering its publishes against
+	// resumes without stalling other accounts.
+	accounts sync.Map
+
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+	recent  map[string][]Event
+}
+
+func NewHub(sendBuffer, replay int, store Store) *Hub {
+	if sendBuffer <= 0 {
+		sendBuffer = 64
+	}
+	if replay <= 0 {
+		replay = 256
+	}
+	return &Hub{
+		sendBuffer: sendBuffer,
+		replay:     replay,
+		store:      store,
+		clients:    make(map[*Client]struct{}),
+		recent:     make(map[string][]Event),
+	}
+}
+
+func (h *Hub) Register() *Client {
+	c := &Client{
+		hub:    h,
+		send:   make(chan []byte, h.sendBuffer),
+		done:   make(chan struct{}),
+		topics: make(map[string]bool),
+	}
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+	return c
+}
+
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+	c.Close()
+}
+
+// Publish delivers e to subscribed clients. Account events are first saved
+// to the store, when there is one, for replay; an event that cannot be
+// saved is still delivered live, without a sequence number.
+func (h *Hub) Publish(ctx context.Context, e Event) {
+	if e.Account != "" {
+		mu := h.accountLock(e.Account)
+		mu.Lock()
+		defer mu.Unlock()
+		if h.store != nil {
+			stored, err := h.store.Append(ctx, e)
+			if err != nil {
+				log.Printf("realtime: store %s event for %s: %v", e.Topic, e.Account, err)
+			} else {
+				e = stored
+			}
+		}
+	}
+	if e.At.IsZero() {
+		e.At = time.Now().UTC()
+	}
+	msg, err := json.Marshal(struct {
+		Type string `json:"type"`
+		Event
+	}{"event", e})
+	if err != nil {
+		log.Printf("realtime: encode %s event: %v", e.Topic, err)
+		return
+	}
+	h.mu.Lock()
+	if e.Seq > 0 {
+		recent := append(h.recent[e.Account], e)
+		if len(recent) > h.replay {
+			recent = slices.Delete(recent, 0, len(recent)-h.replay)
+		}
+		h.recent[e.Account] = recent
+	}
+	clients := make([]*Client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.Unlock()
+	// Enqueue outside mu so one publish does not hold up registrations or
+	// other accounts; the account lock still keeps this account in order.
+	for _, c := range clients {
+		if c.Subscribed(e.Topic) && c.receives(e) {
+			c.Enqueue(msg)
+		}
+	}
+}
+
+func (h *Hub) accountLock(account string) *sync.Mutex {
+	mu, _ := h.accounts.LoadOrStore(account, new(sync.Mutex))
+	return mu.(*sync.Mutex)
+}
+
+// LatestSeq is the account's last published sequence number.
+func (h *Hub) LatestSeq(ctx context.Context, account string) (int64, error) {
+	h.mu.RLock()
+	var latest int64
+	if recent := h.recent[account]; len(recent) > 0 {
+		latest = recent[len(recent)-1].Seq
+	}
+	h.mu.RUnlock()
+	if latest > 0 || h.store == nil {
+		return latest, nil
+	}
+	return h.store.LatestSeq(ctx, account)
+}
+
+// Resume queues, as one replay message, the events after seq on c's
+// subscribed topics. Stored events are loaded first; the account's lock is
+// then held while the gap up to the latest published event is filled from
+// memory, so no live event slips in between, and live delivery continues
+// after the replay. Publish stores events under the same lock, so an event
+// is never replayed before its live delivery; events the client already
+// received live are left out. It reports false when the gap is larger than the replay
+// buffer or the events were pruned, and the client has to reload its state.
+func (h *Hub) Resume(ctx context.Context, c *Client, after int64) (int64, bool, error) {
+	account := c.Account()
+	var (
+		stored       []Event
+		storedLatest int64
+	)
+	if h.store != nil {
+		var err error
+		if storedLatest, err = h.store.LatestSeq(ctx, account); err != nil {
+			return 0, false, err
+		}
+		if after < storedLatest && storedLatest-after <= int64(h.replay) {
+			if stored, err = h.store.EventsAfter(ctx, account, after, h.replay); err != nil {
+				return 0, false, err
+			}
+		}
+	}
+
+	mu := h.accountLock(account)
+	mu.Lock()
+	defer mu.Unlock()
+	// Only this account's publishes change its recent events, and they wait
+	// for mu, so the slice is stable once read.
+	h.mu.RLock()
+	recent := h.recent[account]
+	h.mu.RUnlock()
+
+	latest := storedLatest
+	if len(recent) > 0 {
+		latest = max(latest, recent[len(recent)-1].Seq)
+	}
+	if after == latest {
+		return latest, true, nil
+	}
+	if after > latest || latest-after > int64(h.replay) {
+		return latest, false, nil
+	}
+	// Events published since the store was read are only in recent.
+	next := after + 1
+	if len(stored) > 0 {
+		next = stored[len(stored)-1].Seq + 1
+	}
+	i, _ := slices.BinarySearchFunc(recent, next, func(e Event, seq int64) int { return cmp.Compare(e.Seq, seq) })
+	missed := append(slices.Clip(stored), recent[i:]...)
+	if int64(len(missed)) != latest-after {
+		return latest, false, nil
+	}
+	live := c.firstLive()
+	events := make([]Event, 0, len(missed))
+	for i, e := range missed {
+		if e.Seq != after+1+int64(i) {
+			return latest, false, nil
+		}
+		if c.Subscribed(e.Topic) && (live == 0 || e.Seq < live) {
+			events = append(events, e)
+		}
+	}
+	msg, err := json.Marshal(map[string]any{"type": "replay", "from": after, "to": latest, "events": events})
+	if err != nil {
+		return 0, false, err
+	}
+	c.Enqueue(msg)
+	return latest, true, nil
+}
+
+// Client is one connection's subscriptions and outbound queue. Done is
+// closed when the client is unregistered or dropped as a slow consumer.
+type Client struct {
+	hub  *Hub
+	send chan []byte
+
+	done      chan struct{}
+	closeOnce sync.Once
+	dropped   atomic.Bool
+
+	mu      sync.Mutex
+	account string
+	topics  map[string]bool
+	// live is the first seq delivered live; a replay leaves it and later
+	// events out since the client already has them.
+	live int64
+}
+
+func (c *Client) Send() <-chan []byte   { return c.send }
+func (c *Client) Done() <-chan struct{} { return c.done }
+
+// Enqueue queues msg without blocking. A full buffer means the client is not
+// keeping up, so it is closed instead.
+func (c *Client) Enqueue(msg []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		c.dropped.Store(true)
+		c.Close()
+		return false
+	}
+}
+
+// Dropped reports whether the client was closed for falling behind.
+func (c *Client) Dropped() bool { return c.dropped.Load() }
+
+func (c *Client) Close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+// SetAccount binds the client to the account it authenticated as.
+func (c *Client) SetAccount(account string) {
+	c.mu.Lock()
+	c.account = account
+	c.mu.Unlock()
+}
+
+func (c *Client) Account() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.account
+}
+
+func (c *Client) firstLive() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.live
+}
+
+// receives reports whether e is visible to the client, and notes the first
+// sequenced event it lets through: account events only reach that
+// account's clients, and market events reach everyone.
+func (c *Client) receives(e Event) bool {
+	if e.Account == "" {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.account == "" || !strings.EqualFold(c.account, e.Account) {
+		return false
+	}
+	if e.Seq > 0 && c.live == 0 {
+		c.live = e.Seq
+	}
+	return true
+}
+
+func (c *Client) Subscribe(topic string) {
+	c.mu.Lock()
+	c.topics[topic] = true
+	c.mu.Unlock()
+}
+
+func (c *Client) Unsubscribe(topic string) {
+	c.mu.Lock()
+	delete(c.topics, topic)
+	c.mu.Unlock()
+}
+
+func (c *Client) Subscribed(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.topics[topic]
+}
+
+// Topics returns the client's subscriptions in order.
+func (c *Client) Topics() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]string, 0, len(c.topics))
+	for t := range c.topics {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
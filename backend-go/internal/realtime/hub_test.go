@@ -0,0 +1,231 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// memStore is a Store for one account. Reads see the events from seq
+// pruned+1 up to visible, so a test can stand in for a store that lags
+// behind or has dropped old events.
+type memStore struct {
+	mu      sync.Mutex
+	events  []Event
+	visible int64
+	pruned  int64
+}
+
+func (s *memStore) Append(_ context.Context, e Event) (Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.Seq = int64(len(s.events)) + 1
+	s.events = append(s.events, e)
+	s.visible = e.Seq
+	return e, nil
+}
+
+func (s *memStore) LatestSeq(context.Context, string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.visible, nil
+}
+
+func (s *memStore) EventsAfter(_ context.Context, _ string, after int64, limit int) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Event
+	for _, e := range s.events[:s.visible] {
+		if e.Seq > after && e.Seq > s.pruned && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// subscriber registers a client for account (empty for none) on topics.
+func subscriber(h *Hub, account string, topics ...string) *Client {
+	c := h.Register()
+	c.SetAccount(account)
+	for _, t := range topics {
+		c.Subscribe(t)
+	}
+	return c
+}
+
+// received is what a client has been sent: live events and the contents
+// of replay messages, in arrival order.
+type received struct {
+	topics  []string
+	seqs    []int64
+	replays int
+}
+
+func drain(t *testing.T, c *Client) received {
+	t.Helper()
+	var out received
+	add := func(e Event) {
+		out.topics = append(out.topics, e.Topic)
+		if e.Seq > 0 {
+			out.seqs = append(out.seqs, e.Seq)
+		}
+	}
+	for {
+		select {
+		case msg := <-c.Send():
+			var m struct {
+				Type   string  `json:"type"`
+				Events []Event `json:"events"`
+				Event
+			}
+			if err := json.Unmarshal(msg, &m); err != nil {
+				t.Fatal(err)
+			}
+			if m.Type == "replay" {
+				out.replays++
+				for _, e := range m.Events {
+					add(e)
+				}
+				continue
+			}
+			add(m.Event)
+		default:
+			return out
+		}
+	}
+}
+
+func publish(h *Hub, topics ...string) {
+	for _, topic := range topics {
+		h.Publish(context.Background(), Event{Topic: topic, Account: "0xabc"})
+	}
+}
+
+func TestPublishTopicFiltering(t *testing.T) {
+	h := NewHub(8, 8, nil)
+	orders := subscriber(h, "0xAbc", TopicOrders)
+	other := subscriber(h, "0xdef", TopicOrders)
+	fills := subscriber(h, "0xabc", TopicFills)
+	anonymous := subscriber(h, "", TopicOrders, MarketTopic("BTC", "1m"))
+
+	h.Publish(context.Background(), Event{Seq: 1, Topic: TopicOrders, Account: "0xabc"})
+	h.Publish(context.Background(), Event{Topic: MarketTopic("BTC", "1m")})
+	h.Publish(context.Background(), Event{Topic: MarketTopic("ETH", "1m")})
+
+	for name, tt := range map[string]struct {
+		c    *Client
+		want string
+	}{
+		"account orders": {orders, "[orders]"},
+		"other account":  {other, "[]"},
+		"other topic":    {fills, "[]"},
+		"unauthed":       {anonymous, "[market.BTC.1m]"},
+	} {
+		if got := drain(t, tt.c).topics; fmt.Sprint(got) != tt.want {
+			t.Errorf("%s received %v, want %s", name, got, tt.want)
+		}
+	}
+}
+
+func TestPublishDropsSlowConsumer(t *testing.T) {
+	h := NewHub(2, 8, nil)
+	slow := subscriber(h, "0xabc", TopicOrders)
+	fast := subscriber(h, "0xabc", TopicOrders)
+
+	var got []int64
+	for seq := int64(1); seq <= 3; seq++ {
+		h.Publish(context.Background(), Event{Seq: seq, Topic: TopicOrders, Account: "0xabc"})
+		got = append(got, drain(t, fast).seqs...)
+	}
+	if len(got) != 3 || fast.Dropped() {
+		t.Fatalf("fast consumer received %v, dropped %v", got, fast.Dropped())
+	}
+	if !slow.Dropped() {
+		t.Fatal("slow consumer was not dropped")
+	}
+	select {
+	case <-slow.Done():
+	default:
+		t.Fatal("dropped client not closed")
+	}
+	if n := len(slow.Send()); n != 2 {
+		t.Errorf("slow consumer holds %d messages, want its buffer of 2", n)
+	}
+	// Later publishes skip the closed client without blocking.
+	h.Publish(context.Background(), Event{Seq: 4, Topic: TopicOrders, Account: "0xabc"})
+	if n := len(slow.Send()); n != 2 {
+		t.Errorf("closed client received more messages: %d", n)
+	}
+}
+
+func TestResume(t *testing.T) {
+	tests := []struct {
+		name    string
+		after   int64
+		visible int64 // store reads stop here; 0 for all six events
+		pruned  int64
+		ok      bool
+		want    string // seqs replayed on the orders topic
+	}{
+		{name: "stored and recent overlap", after: 3, ok: true, want: "[4 6]"},
+		{name: "store behind recent", after: 3, visible: 5, ok: true, want: "[4 6]"},
+		{name: "up to date", after: 6, ok: true, want: "[]"},
+		{name: "gap over the replay limit", after: 2, ok: false, want: "[]"},
+		{name: "stored events pruned", after: 3, pruned: 4, ok: false, want: "[]"},
+		{name: "ahead of the account", after: 7, ok: false, want: "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memStore{}
+			// Recent events keep the last three of the six published.
+			h := NewHub(16, 3, store)
+			publish(h, TopicOrders, TopicFills, TopicOrders, TopicOrders, TopicFills, TopicOrders)
+			if tt.visible > 0 {
+				store.visible = tt.visible
+			}
+			store.pruned = tt.pruned
+
+			c := subscriber(h, "0xabc", TopicOrders)
+			latest, ok, err := h.Resume(context.Background(), c, tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.ok || latest != 6 {
+				t.Fatalf("Resume() = %d, %v; want 6, %v", latest, ok, tt.ok)
+			}
+			if got := drain(t, c).seqs; fmt.Sprint(got) != tt.want {
+				t.Errorf("replayed %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// Every event after the resume point reaches the client exactly once,
+// whether it was published before, during or after the replay.
+func TestResumeDeliversOnce(t *testing.T) {
+	h := NewHub(16, 8, &memStore{})
+	publish(h, TopicOrders, TopicOrders, TopicOrders)
+
+	// Replay then live: events after the replay arrive only live.
+	late := subscriber(h, "0xabc", TopicOrders)
+	if _, ok, err := h.Resume(context.Background(), late, 1); !ok || err != nil {
+		t.Fatalf("Resume() = %v, %v", ok, err)
+	}
+	publish(h, TopicOrders)
+	if got := drain(t, late); fmt.Sprint(got.seqs) != "[2 3 4]" || got.replays != 1 {
+		t.Errorf("resume then live received %v in %d replays, want [2 3 4] in 1", got.seqs, got.replays)
+	}
+
+	// Live then replay: events already delivered live are not replayed.
+	early := subscriber(h, "0xabc", TopicOrders)
+	publish(h, TopicOrders, TopicOrders)
+	if _, ok, err := h.Resume(context.Background(), early, 2); !ok || err != nil {
+		t.Fatalf("Resume() = %v, %v", ok, err)
+	}
+	publish(h, TopicOrders)
+	if got := drain(t, early).seqs; fmt.Sprint(got) != "[5 6 3 4 7]" {
+		t.Errorf("live then resume received %v, want [5 6 3 4 7]", got)
+	}
+}
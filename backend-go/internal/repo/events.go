@@ -0,0 +1,67 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"autotrade/backend-go/internal/model"
+)
+
+// AppendEvent stores an account event under the account's next sequence
+// number.
+func (r *Repo) AppendEvent(ctx context.Context, account, topic string, data any) (int64, time.Time, error) {
+	var seq int64
+	var createdAt time.Time
+	err := r.db.QueryRow(ctx, `
+		WITH next AS (
+			INSERT INTO event_sequences (account, seq) VALUES ($1, 1)
+			ON CONFLICT (account) DO UPDATE SET seq = event_sequences.seq + 1
+			RETURNING seq
+		)
+		INSERT INTO ws_events (account, seq, topic, data)
+		SELECT $1, seq, $2, $3 FROM next
+		RETURNING seq, created_at;
+	`, account, topic, data).Scan(&seq, &createdAt)
+	return seq, createdAt, err
+}
+
+func (r *Repo) LatestEventSeq(ctx context.Context, account string) (int64, error) {
+	var seq int64
+	err := r.db.QueryRow(ctx, `
+		SELECT COALESCE((SELECT seq FROM event_sequences WHERE account = $1), 0);
+	`, account).Scan(&seq)
+	return seq, err
+}
+
+func (r *Repo) EventsAfter(ctx context.Context, account string, after int64, limit int) ([]model.StreamEvent, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT account, seq, topic, data, created_at
+		FROM ws_events
+		WHERE account = $1 AND seq > $2
+		ORDER BY seq
+		LIMIT $3;
+	`, account, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.StreamEvent, 0)
+	for rows.Next() {
+		var e model.StreamEvent
+		if err := rows.Scan(&e.Account, &e.Seq, &e.Topic, &e.Data, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// PruneEvents drops events older than before; sequences keep counting.
+func (r *Repo) PruneEvents(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM ws_events WHERE created_at < $1;`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,42 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"autotrade/backend-go/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetFundingSnapshot returns the funding rate and close from the latest
+// snapshot captured at or before at, no older than maxAge.
+func (r *Repo) GetFundingSnapshot(ctx context.Context, symbol string, at time.Time, maxAge time.Duration) (rate, mark float64, found bool, err error) {
+	err = r.db.QueryRow(ctx, `
+		SELECT funding_rate, COALESCE((ohlcv->>'close')::double precision, 0)
+		FROM market_snapshots
+		WHERE symbol = $1 AND captured_at <= $2 AND captured_at > $3 AND funding_rate IS NOT NULL
+		ORDER BY captured_at DESC
+		LIMIT 1;
+	`, symbol, at, at.Add(-maxAge)).Scan(&rate, &mark)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, 0, false, nil
+	}
+	return rate, mark, err == nil, err
+}
+
+// InsertFundingPayment records the payment for one position and period. It
+// returns 0 if that period was already charged.
+func (r *Repo) InsertFundingPayment(ctx context.Context, account string, p model.FundingPayment) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO funding_payments (account, symbol, period, side, size, mark_price, rate, amount)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (account, symbol, period) DO NOTHING
+		RETURNING id;
+	`, account, p.Symbol, p.Period, p.Side, p.Size, p.MarkPrice, p.Rate, p.Amount).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
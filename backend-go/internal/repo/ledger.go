@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"context"
+
+	"autotrade/backend-go/internal/model"
+)
+
+// InsertLedgerEntry records a cash movement. Entries with a reference are
+// unique per (kind, ref), so re-posting the same fill or funding period is a
+// no-op and reported as false.
+func (r *Repo) InsertLedgerEntry(ctx context.Context, account string, e model.LedgerEntry) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		INSERT INTO ledger_entries (account, execution, kind, amount, symbol, ref_type, ref_id, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (kind, ref_type, ref_id) WHERE ref_id IS NOT NULL DO NOTHING;
+	`, account, e.Execution, e.Kind, e.Amount, e.Symbol, e.RefType, e.RefID, e.Note)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *Repo) GetLedger(ctx context.Context, account string, q model.LedgerQuery) ([]model.LedgerEntry, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, execution, kind, amount, balance, symbol, ref_type, ref_id, note, created_at
+		FROM (
+			SELECT *, SUM(amount) OVER (ORDER BY created_at, id) AS balance
+			FROM ledger_entries
+			WHERE account = $1 AND execution = $2
+		) l
+		WHERE ($3::timestamptz IS NULL OR created_at >= $3)
+			AND ($4::timestamptz IS NULL OR created_at < $4)
+			AND ($5 = '' OR kind = $5)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $6;
+	`, account, q.Execution, q.From, q.To, q.Kind, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.LedgerEntry, 0, q.Limit)
+	for rows.Next() {
+		var e model.LedgerEntry
+		if err := rows.Scan(&e.ID, &e.Execution, &e.Kind, &e.Amount, &e.Balance, &e.Symbol, &e.RefType, &e.RefID, &e.Note, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// GetBalance returns the execution's ledger cash balance and the realised
+// PnL within it.
+func (r *Repo) GetBalance(ctx context.Context, account, execution string) (balance, realized float64, err error) {
+	err = r.db.QueryRow(ctx, `
+		SELECT
+			COALESCE(SUM(amount), 0),
+			COALESCE(SUM(amount) FILTER (WHERE kind = 'realised_pnl'), 0)
+		FROM ledger_entries
+		WHERE account = $1 AND execution = $2;
+	`, account, execution).Scan(&balance, &realized)
+	return balance, realized, err
+}
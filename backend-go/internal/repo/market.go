@@ -0,0 +1,160 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"autotrade/backend-go/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetMarks returns the latest snapshot close per symbol. Symbols without a
+// usable snapshot are missing from the map.
+func (r *Repo) GetMarks(ctx context.Context, symbols []string) (map[string]model.Mark, error) {
+	out := make(map[string]model.Mark, len(symbols))
+	if len(symbols) == 0 {
+		return out, nil
+	}
+	rows, err := r.db.Query(ctx, `
+		SELECT DISTINCT ON (symbol) symbol, (ohlcv->>'close')::double precision, captured_at
+		FROM market_snapshots
+		WHERE symbol = ANY($1) AND (ohlcv->>'close')::double precision > 0
+		ORDER BY symbol, captured_at DESC;
+	`, symbols)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m model.Mark
+		if err := rows.Scan(&m.Symbol, &m.Price, &m.CapturedAt); err != nil {
+			return nil, err
+		}
+		out[m.Symbol] = m
+	}
+	return out, rows.Err()
+}
+
+// GetCandlesAfter returns snapshots of timeframe with id above afterID, oldest first.
+func (r *Repo) GetCandlesAfter(ctx context.Context, timeframe string, afterID int64, limit int) ([]model.Candle, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+candleColumns+`
+		FROM market_snapshots
+		WHERE timeframe = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3;
+	`, timeframe, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.Candle, 0, limit)
+	for rows.Next() {
+		c, err := scanCandle(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// PaperCursor returns the last snapshot the paper matcher processed. On first
+// use it starts from the newest snapshot so history is not replayed.
+func (r *Repo) PaperCursor(ctx context.Context, timeframe string) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(ctx, `
+		WITH ins AS (
+			INSERT INTO paper_cursor (timeframe, last_snapshot_id)
+			SELECT $1, COALESCE(MAX(id), 0) FROM market_snapshots
+			ON CONFLICT (timeframe) DO NOTHING
+			RETURNING last_snapshot_id
+		)
+		SELECT last_snapshot_id FROM ins
+		UNION ALL
+		SELECT last_snapshot_id FROM paper_cursor WHERE timeframe = $1
+		LIMIT 1;
+	`, timeframe).Scan(&id)
+	return id, err
+}
+
+func (r *Repo) SavePaperCursor(ctx context.Context, timeframe string, id int64) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE paper_cursor SET last_snapshot_id = $2, updated_at = now() WHERE timeframe = $1;
+	`, timeframe, id)
+	return err
+}
+
+const candleColumns = `id, symbol,
+	COALESCE((ohlcv->>'open')::double precision, 0),
+	COALESCE((ohlcv->>'high')::double precision, 0),
+	COALESCE((ohlcv->>'low')::double precision, 0),
+	COALESCE((ohlcv->>'close')::double precision, 0),
+	COALESCE((ohlcv->>'volume')::double precision, 0),
+	COALESCE((exec_context->>'volatility')::double precision, 0),
+	captured_at`
+
+func scanCandle(row pgx.Row) (model.Candle, error) {
+	var c model.Candle
+	err := row.Scan(&c.ID, &c.Symbol, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume, &c.Volatility, &c.CapturedAt)
+	return c, err
+}
+
+// GetCandle returns the snapshot with the given id.
+func (r *Repo) GetCandle(ctx context.Context, id int64) (model.Candle, error) {
+	return scanCandle(r.db.QueryRow(ctx, `
+		SELECT `+candleColumns+`
+		FROM market_snapshots
+		WHERE id = $1;
+	`, id))
+}
+
+// ChangesSince lists rows of the notifying tables changed after since, for
+// catching up on notifications missed while not listening. Only the latest
+// snapshot per symbol and timeframe is returned.
+func (r *Repo) ChangesSince(ctx context.Context, since time.Time) ([]model.Change, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT 'orders', id, account, symbol, '', updated_at FROM orders WHERE updated_at > $1
+		UNION ALL
+		SELECT 'fills', id, account, symbol, '', created_at FROM fills WHERE created_at > $1
+		UNION ALL
+		SELECT 'strategy_runtime', 0, account, '', '', updated_at FROM strategy_runtime WHERE updated_at > $1
+		UNION ALL
+		SELECT 'control_state', 0, account, '', '', updated_at FROM control_state WHERE updated_at > $1
+		UNION ALL
+		SELECT * FROM (
+			SELECT DISTINCT ON (symbol, timeframe) 'market_snapshots', id, '', symbol, timeframe, captured_at
+			FROM market_snapshots
+			WHERE captured_at > $1
+			ORDER BY symbol, timeframe, captured_at DESC
+		) latest
+		ORDER BY 6;
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.Change, 0)
+	for rows.Next() {
+		c := model.Change{Op: "resync"}
+		if err := rows.Scan(&c.Table, &c.ID, &c.Account, &c.Symbol, &c.Timeframe, &c.At); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// GetLatestCandle returns the newest snapshot of timeframe for symbol.
+func (r *Repo) GetLatestCandle(ctx context.Context, symbol, timeframe string) (model.Candle, error) {
+	return scanCandle(r.db.QueryRow(ctx, `
+		SELECT `+candleColumns+`
+		FROM market_snapshots
+		WHERE symbol = $1 AND timeframe = $2
+		ORDER BY captured_at DESC
+		LIMIT 1;
+	`, symbol, timeframe))
+}
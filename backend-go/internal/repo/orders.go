@@ -0,0 +1,360 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"autotrade/backend-go/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+const orderColumns = `id, symbol, side, order_type, size, filled_size, entry_price, stop_loss, take_profit, status, execution, parent_id, role, venue_order_id, created_at, updated_at`
+
+func scanOrder(row pgx.Row) (model.Order, error) {
+	var it model.Order
+	err := row.Scan(&it.ID, &it.Symbol, &it.Side, &it.OrderType, &it.Size, &it.FilledSize, &it.EntryPrice, &it.StopLoss, &it.TakeProfit, &it.Status, &it.Execution, &it.ParentID, &it.Role, &it.VenueOrderID, &it.CreatedAt, &it.UpdatedAt)
+	return it, err
+}
+
+// CreateOrder inserts a pending order and its "created" event. Call it
+// inside WithTx so both commit together.
+func (r *Repo) CreateOrder(ctx context.Context, account string, in model.OrderInput) (int64, error) {
+	var id int64
+	if err := r.db.QueryRow(ctx, `
+		INSERT INTO orders
+		(account, symbol, side, order_type, size, entry_price, stop_loss, take_profit, execution, client_tag, status)
+		VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 'pending')
+		RETURNING id;
+	`, account, in.Symbol, in.Side, in.OrderType, in.Size, in.EntryPrice, in.StopLoss, in.TakeProfit, in.Execution, in.ClientTag).Scan(&id); err != nil {
+		return 0, err
+	}
+	if err := insertOrderEvent(ctx, r.db, account, id, "created", "", model.OrderPending, nil); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// CreateChildOrder adds a pending bracket leg under an entry order. The
+// leg inherits symbol, execution and account; price is the stop trigger or
+// limit price. Call it inside WithTx.
+func (r *Repo) CreateChildOrder(ctx context.Context, account string, parent model.Order, role, side, orderType string, size, price float64) (int64, error) {
+	var id int64
+	if err := r.db.QueryRow(ctx, `
+		INSERT INTO orders
+		(account, symbol, side, order_type, size, entry_price, stop_loss, take_profit, execution, client_tag, status, parent_id, role)
+		VALUES
+		($1, $2, $3, $4, $5, $6, 0, 0, $7, '', 'pending', $8, $9)
+		RETURNING id;
+	`, account, parent.Symbol, side, orderType, size, price, parent.Execution, parent.ID, role).Scan(&id); err != nil {
+		return 0, err
+	}
+	if err := insertOrderEvent(ctx, r.db, account, id, "created", "", model.OrderPending, map[string]any{"parentId": parent.ID, "role": role}); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetSiblingOrders returns the other working legs under the same parent.
+func (r *Repo) GetSiblingOrders(ctx context.Context, account string, parentID, excludeID int64) ([]model.Order, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE account = $1 AND parent_id = $2 AND id <> $3 AND status IN ('pending', 'open', 'partially_filled');
+	`, account, parentID, excludeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.Order, 0, 1)
+	for rows.Next() {
+		it, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+// GetWorkingPaperOrders returns open paper orders on symbol placed at or
+// before the given time, across all accounts.
+func (r *Repo) GetWorkingPaperOrders(ctx context.Context, symbol string, before time.Time) ([]model.AccountOrder, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT account, `+orderColumns+`
+		FROM orders
+		WHERE symbol = $1 AND created_at <= $2 AND status IN ('open', 'partially_filled') AND execution = ANY($3)
+		ORDER BY created_at, id;
+	`, symbol, before, model.BookExecutions(model.ExecutionPaper))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.AccountOrder, 0)
+	for rows.Next() {
+		var it model.AccountOrder
+		if err := rows.Scan(&it.Account, &it.ID, &it.Symbol, &it.Side, &it.OrderType, &it.Size, &it.FilledSize, &it.EntryPrice, &it.StopLoss, &it.TakeProfit, &it.Status, &it.Execution, &it.ParentID, &it.Role, &it.VenueOrderID, &it.CreatedAt, &it.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+// GetPendingOrders returns orders of the given execution that are still
+// waiting to be admitted, oldest first.
+func (r *Repo) GetPendingOrders(ctx context.Context, execution string) ([]model.AccountOrder, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT account, `+orderColumns+`
+		FROM orders
+		WHERE status = 'pending' AND execution = $1
+		ORDER BY id;
+	`, execution)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.AccountOrder, 0)
+	for rows.Next() {
+		var it model.AccountOrder
+		if err := rows.Scan(&it.Account, &it.ID, &it.Symbol, &it.Side, &it.OrderType, &it.Size, &it.FilledSize, &it.EntryPrice, &it.StopLoss, &it.TakeProfit, &it.Status, &it.Execution, &it.ParentID, &it.Role, &it.VenueOrderID, &it.CreatedAt, &it.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repo) SetVenueOrderID(ctx context.Context, account string, id int64, venueOrderID string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE orders SET venue_order_id = $3, updated_at = now() WHERE account = $1 AND id = $2;
+	`, account, id, venueOrderID)
+	return err
+}
+
+// CreateFill stores a fill; a zero CreatedAt means now.
+func (r *Repo) CreateFill(ctx context.Context, account string, f model.Fill) (int64, error) {
+	createdAt := f.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+	var id int64
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO fills (account, order_id, symbol, side, price, size, realized_pnl, fee, slippage, liquidity, status, venue_trade_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id;
+	`, account, f.OrderID, f.Symbol, f.Side, f.Price, f.Size, f.RealizedPnL, f.Fee, f.Slippage, f.Liquidity, f.Status, f.VenueTradeID, createdAt).Scan(&id)
+	return id, err
+}
+
+// HasPlacementFill reports whether the order has a fill recorded from its
+// placement response, which carries no trade id, at or after at.
+func (r *Repo) HasPlacementFill(ctx context.Context, account string, orderID int64, at time.Time) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM fills WHERE account = $1 AND order_id = $2 AND venue_trade_id = '' AND created_at >= $3);
+	`, account, orderID, at).Scan(&exists)
+	return exists, err
+}
+
+// HasVenueFill reports whether a venue trade was already recorded.
+func (r *Repo) HasVenueFill(ctx context.Context, account, venueTradeID string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM fills WHERE account = $1 AND venue_trade_id = $2);
+	`, account, venueTradeID).Scan(&exists)
+	return exists, err
+}
+
+func (r *Repo) GetOrders(ctx context.Context, account string, limit int) ([]model.Order, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE account = $1
+		ORDER BY created_at DESC
+		LIMIT $2;
+	`, account, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.Order, 0, limit)
+	for rows.Next() {
+		it, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repo) GetOrder(ctx context.Context, account string, id int64) (model.Order, error) {
+	return scanOrder(r.db.QueryRow(ctx, `
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE account = $1 AND id = $2;
+	`, account, id))
+}
+
+func (r *Repo) GetOrderByVenueID(ctx context.Context, account, venueOrderID string) (model.Order, error) {
+	return scanOrder(r.db.QueryRow(ctx, `
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE account = $1 AND venue_order_id = $2
+		ORDER BY id DESC
+		LIMIT 1;
+	`, account, venueOrderID))
+}
+
+// GetWorkingOrders lists the account's pending, open and partially filled
+// orders in any of the given executions, oldest first.
+func (r *Repo) GetWorkingOrders(ctx context.Context, account string, executions []string) ([]model.Order, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE account = $1 AND execution = ANY($2) AND status IN ('pending', 'open', 'partially_filled')
+		ORDER BY id;
+	`, account, executions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.Order, 0)
+	for rows.Next() {
+		it, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+// TransitionOrder moves an order from one status to another only if it is
+// still in the expected status, recording the change in order_events. Call
+// it inside WithTx.
+func (r *Repo) TransitionOrder(ctx context.Context, account string, id int64, from, to string, filledSize float64, detail map[string]any) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE orders
+		SET status = $4, filled_size = GREATEST(filled_size, $5), updated_at = now()
+		WHERE account = $1 AND id = $2 AND status = $3;
+	`, account, id, from, to, filledSize)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	if err := insertOrderEvent(ctx, r.db, account, id, "transition", from, to, detail); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AmendOrder applies the non-nil fields of in if the order is still in
+// status, recording an "amended" event. Call it inside WithTx.
+func (r *Repo) AmendOrder(ctx context.Context, account string, id int64, status string, in model.OrderAmend) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE orders
+		SET entry_price = COALESCE($4, entry_price),
+			size = COALESCE($5, size),
+			stop_loss = COALESCE($6, stop_loss),
+			take_profit = COALESCE($7, take_profit),
+			updated_at = now()
+		WHERE account = $1 AND id = $2 AND status = $3;
+	`, account, id, status, in.EntryPrice, in.Size, in.StopLoss, in.TakeProfit)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	detail := map[string]any{}
+	if in.EntryPrice != nil {
+		detail["entryPrice"] = *in.EntryPrice
+	}
+	if in.Size != nil {
+		detail["size"] = *in.Size
+	}
+	if in.StopLoss != nil {
+		detail["stopLoss"] = *in.StopLoss
+	}
+	if in.TakeProfit != nil {
+		detail["takeProfit"] = *in.TakeProfit
+	}
+	if err := insertOrderEvent(ctx, r.db, account, id, "amended", status, status, detail); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *Repo) GetOrderEvents(ctx context.Context, account string, orderID int64) ([]model.OrderEvent, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, order_id, event, from_status, to_status, detail, created_at
+		FROM order_events
+		WHERE account = $1 AND order_id = $2
+		ORDER BY id;
+	`, account, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.OrderEvent, 0)
+	for rows.Next() {
+		var it model.OrderEvent
+		if err := rows.Scan(&it.ID, &it.OrderID, &it.Event, &it.FromStatus, &it.ToStatus, &it.Detail, &it.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+func insertOrderEvent(ctx context.Context, db dbtx, account string, orderID int64, event, from, to string, detail map[string]any) error {
+	if detail == nil {
+		detail = map[string]any{}
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO order_events (account, order_id, event, from_status, to_status, detail)
+		VALUES ($1, $2, $3, $4, $5, $6);
+	`, account, orderID, event, from, to, detail)
+	return err
+}
+
+// ClaimIdempotencyKey reserves key for the account, returning false if it
+// already exists.
+func (r *Repo) ClaimIdempotencyKey(ctx context.Context, account, key, requestHash string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		INSERT INTO order_idempotency (account, key, request_hash)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (account, key) DO NOTHING;
+	`, account, key, requestHash)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *Repo) GetIdempotencyKey(ctx context.Context, account, key string) (model.IdempotencyKey, error) {
+	var out model.IdempotencyKey
+	err := r.db.QueryRow(ctx, `
+		SELECT key, request_hash, order_id, created_at
+		FROM order_idempotency
+		WHERE account = $1 AND key = $2;
+	`, account, key).Scan(&out.Key, &out.RequestHash, &out.OrderID, &out.CreatedAt)
+	return out, err
+}
+
+func (r *Repo) AttachIdempotencyKey(ctx context.Context, account, key string, orderID int64) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE order_idempotency SET order_id = $3 WHERE account = $1 AND key = $2;
+	`, account, key, orderID)
+	return err
+}
@@ -0,0 +1,116 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"autotrade/backend-go/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+const positionColumns = `execution, symbol, side, size, avg_entry, realized_pnl, cumulative_funding, opened_at, updated_at`
+
+func scanPosition(row pgx.Row) (model.Position, error) {
+	var p model.Position
+	err := row.Scan(&p.Execution, &p.Symbol, &p.Side, &p.Size, &p.AvgEntry, &p.RealizedPnL, &p.CumulativeFunding, &p.OpenedAt, &p.UpdatedAt)
+	return p, err
+}
+
+// GetPositions returns the account's open positions in one execution, or in
+// both when execution is empty.
+func (r *Repo) GetPositions(ctx context.Context, account, execution string) ([]model.Position, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+positionColumns+`
+		FROM positions
+		WHERE account = $1 AND ($2 = '' OR execution = $2) AND size > 0
+		ORDER BY execution, symbol;
+	`, account, execution)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.Position, 0)
+	for rows.Next() {
+		p, err := scanPosition(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repo) GetPosition(ctx context.Context, account, execution, symbol string) (model.Position, error) {
+	return scanPosition(r.db.QueryRow(ctx, `
+		SELECT `+positionColumns+`
+		FROM positions
+		WHERE account = $1 AND execution = $2 AND symbol = $3;
+	`, account, execution, symbol))
+}
+
+// LockPosition returns the position row locked for update, creating a flat
+// one first so concurrent fills on a new symbol serialise on the same row.
+// Call it inside WithTx.
+func (r *Repo) LockPosition(ctx context.Context, account, execution, symbol string) (model.Position, error) {
+	if _, err := r.db.Exec(ctx, `
+		INSERT INTO positions (account, execution, symbol)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (account, execution, symbol) DO NOTHING;
+	`, account, execution, symbol); err != nil {
+		return model.Position{}, err
+	}
+	return scanPosition(r.db.QueryRow(ctx, `
+		SELECT `+positionColumns+`
+		FROM positions
+		WHERE account = $1 AND execution = $2 AND symbol = $3
+		FOR UPDATE;
+	`, account, execution, symbol))
+}
+
+func (r *Repo) SavePosition(ctx context.Context, account string, p model.Position) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE positions
+		SET side = $4, size = $5, avg_entry = $6, realized_pnl = $7, cumulative_funding = $8, opened_at = $9, updated_at = now()
+		WHERE account = $1 AND execution = $2 AND symbol = $3;
+	`, account, p.Execution, p.Symbol, p.Side, p.Size, p.AvgEntry, p.RealizedPnL, p.CumulativeFunding, p.OpenedAt)
+	return err
+}
+
+// PositionsSince lists every account's positions in one execution that are
+// open now or have changed after since, so were possibly open at since.
+func (r *Repo) PositionsSince(ctx context.Context, execution string, since time.Time) ([]model.AccountPosition, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT account, `+positionColumns+`
+		FROM positions
+		WHERE execution = $1 AND (size > 0 OR updated_at > $2)
+		ORDER BY account, symbol;
+	`, execution, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.AccountPosition, 0)
+	for rows.Next() {
+		var p model.AccountPosition
+		if err := rows.Scan(&p.Account, &p.Execution, &p.Symbol, &p.Side, &p.Size, &p.AvgEntry, &p.RealizedPnL, &p.CumulativeFunding, &p.OpenedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// NetFilledSince sums the account's fills on symbol after since from orders
+// in the given executions, buys positive and sells negative.
+func (r *Repo) NetFilledSince(ctx context.Context, account string, executions []string, symbol string, since time.Time) (float64, error) {
+	var net float64
+	err := r.db.QueryRow(ctx, `
+		SELECT COALESCE(SUM(CASE WHEN f.side = 'Buy' THEN f.size ELSE -f.size END), 0)
+		FROM fills f
+		JOIN orders o ON o.id = f.order_id
+		WHERE f.account = $1 AND o.execution = ANY($2) AND f.symbol = $3 AND f.created_at > $4;
+	`, account, executions, symbol, since).Scan(&net)
+	return net, err
+}
@@ -0,0 +1,70 @@
+package repo
+
+import (
+	"context"
+
+	"autotrade/backend-go/internal/model"
+)
+
+// ReconcileAccounts lists accounts with working orders of the given
+// execution, or any such order updated within the lookback window.
+func (r *Repo) ReconcileAccounts(ctx context.Context, execution string, lookbackSecs float64) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT DISTINCT account
+		FROM orders
+		WHERE execution = $1
+			AND (status IN ('pending', 'open', 'partially_filled') OR updated_at > now() - make_interval(secs => $2))
+		ORDER BY account;
+	`, execution, lookbackSecs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]string, 0)
+	for rows.Next() {
+		var account string
+		if err := rows.Scan(&account); err != nil {
+			return nil, err
+		}
+		out = append(out, account)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repo) SaveReconciliationReport(ctx context.Context, rep model.ReconciliationReport) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO reconciliation_reports
+		(account, execution, orders_checked, orders_updated, orders_orphaned, fills_repaired, unknown_venue_orders, position_drift, error, started_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id;
+	`, rep.Account, rep.Execution, rep.OrdersChecked, rep.OrdersUpdated, rep.OrdersOrphaned, rep.FillsRepaired, rep.UnknownVenueOrders, rep.PositionDrift, rep.Error, rep.StartedAt, rep.FinishedAt).Scan(&id)
+	return id, err
+}
+
+// GetReconciliationReports returns the newest reports, optionally for one
+// account.
+func (r *Repo) GetReconciliationReports(ctx context.Context, account string, limit int) ([]model.ReconciliationReport, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, account, execution, orders_checked, orders_updated, orders_orphaned, fills_repaired, unknown_venue_orders, position_drift, error, started_at, finished_at
+		FROM reconciliation_reports
+		WHERE $1 = '' OR account = $1
+		ORDER BY started_at DESC, id DESC
+		LIMIT $2;
+	`, account, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.ReconciliationReport, 0, limit)
+	for rows.Next() {
+		var rep model.ReconciliationReport
+		if err := rows.Scan(&rep.ID, &rep.Account, &rep.Execution, &rep.OrdersChecked, &rep.OrdersUpdated, &rep.OrdersOrphaned, &rep.FillsRepaired, &rep.UnknownVenueOrders, &rep.PositionDrift, &rep.Error, &rep.StartedAt, &rep.FinishedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, rep)
+	}
+	return out, rows.Err()
+}
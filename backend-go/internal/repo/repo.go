@@ -5,39 +5,99 @@ import (
 	"time"
 
 	"autotrade/backend-go/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// dbtx is satisfied by both *pgxpool.Pool and pgx.Tx, so every Repo method
+// runs unchanged inside or outside a transaction.
+type dbtx interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
 type Repo struct {
-	pool *pgxpool.Pool
+	db dbtx
+	// afterCommit collects hooks for the outermost transaction; nil
+	// outside one.
+	afterCommit *[]func()
 }
 
 func New(pool *pgxpool.Pool) *Repo {
-	return &Repo{pool: pool}
+	return &Repo{db: pool}
 }
 
-func (r *Repo) GetState(ctx context.Context) (model.AccountState, error) {
-	row := r.pool.QueryRow(ctx, `
-		SELECT
-			COALESCE(SUM(1000 + realized_pnl), 1000) AS equity,
-			5.0 AS leverage,
-			COALESCE(SUM(CASE WHEN status = 'open' THEN realized_pnl ELSE 0 END), 0) AS open_pnl,
-			now()
-		FROM fills;
-	`)
+// WithTx runs fn against a Repo bound to a single transaction, committing if
+// fn returns nil and rolling back otherwise. Nested calls use savepoints.
+func (r *Repo) WithTx(ctx context.Context, fn func(tx *Repo) error) error {
+	hooks := r.afterCommit
+	if hooks == nil {
+		hooks = new([]func())
+	}
+	n := len(*hooks)
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		return fn(&Repo{db: tx, afterCommit: hooks})
+	})
+	if err != nil {
+		*hooks = (*hooks)[:n]
+		return err
+	}
+	if r.afterCommit == nil {
+		for _, h := range *hooks {
+			h()
+		}
+	}
+	return nil
+}
 
-	var s model.AccountState
-	err := row.Scan(&s.Equity, &s.Leverage, &s.OpenPnL, &s.UpdatedAt)
-	return s, err
+// AfterCommit runs fn once the enclosing transaction commits, or at once
+// outside a transaction. Hooks of a rolled back transaction never run.
+func (r *Repo) AfterCommit(fn func()) {
+	if r.afterCommit == nil {
+		fn()
+		return
+	}
+	*r.afterCommit = append(*r.afterCommit, fn)
 }
 
-func (r *Repo) GetFills(ctx context.Context, limit int) ([]model.Fill, error) {
-	rows, err := r.pool.Query(ctx, `
-		SELECT id, symbol, side, price, size, realized_pnl, status, created_at
+func (r *Repo) EnsureAccount(ctx context.Context, account string, startingBalance float64) error {
+	if _, err := r.db.Exec(ctx, `
+		INSERT INTO accounts (address) VALUES ($1) ON CONFLICT (address) DO NOTHING;
+	`, account); err != nil {
+		return err
+	}
+	if _, err := r.db.Exec(ctx, `
+		INSERT INTO control_state (account, bias) VALUES ($1, 'Hybrid') ON CONFLICT (account) DO NOTHING;
+	`, account); err != nil {
+		return err
+	}
+	if _, err := r.db.Exec(ctx, `
+		INSERT INTO strategy_runtime (account) VALUES ($1) ON CONFLICT (account) DO NOTHING;
+	`, account); err != nil {
+		return err
+	}
+	if startingBalance <= 0 {
+		return nil
+	}
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO ledger_entries (account, kind, amount, note)
+		SELECT $1, 'deposit', $2, 'paper starting balance'
+		WHERE NOT EXISTS (SELECT 1 FROM ledger_entries WHERE account = $1 AND execution = 'paper' AND kind = 'deposit');
+	`, account, startingBalance)
+	return err
+}
+
+func (r *Repo) GetFills(ctx context.Context, account string, limit int) ([]model.Fill, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, order_id, symbol, side, price, size, realized_pnl, fee, slippage, liquidity, status, venue_trade_id, created_at
 		FROM fills
+		WHERE account = $1
 		ORDER BY created_at DESC
-		LIMIT $1;
-	`, limit)
+		LIMIT $2;
+	`, account, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -46,7 +106,7 @@ func (r *Repo) GetFills(ctx context.Context, limit int) ([]model.Fill, error) {
 	fills := make([]model.Fill, 0, limit)
 	for rows.Next() {
 		var f model.Fill
-		if err := rows.Scan(&f.ID, &f.Symbol, &f.Side, &f.Price, &f.Size, &f.RealizedPnL, &f.Status, &f.CreatedAt); err != nil {
+		if err := rows.Scan(&f.ID, &f.OrderID, &f.Symbol, &f.Side, &f.Price, &f.Size, &f.RealizedPnL, &f.Fee, &f.Slippage, &f.Liquidity, &f.Status, &f.VenueTradeID, &f.CreatedAt); err != nil {
 			return nil, err
 		}
 		fills = append(fills, f)
@@ -54,16 +114,55 @@ func (r *Repo) GetFills(ctx context.Context, limit int) ([]model.Fill, error) {
 	return fills, rows.Err()
 }
 
-func (r *Repo) SaveReview(ctx context.Context, in model.ReviewInput) error {
-	_, err := r.pool.Exec(ctx, `
-		INSERT INTO reviews (fill_id, verdict, tags, notes)
-		VALUES ($1, $2, $3, $4);
-	`, in.FillID, in.Verdict, in.Tags, in.Notes)
-	return err
+func (r *Repo) GetFill(ctx context.Context, account string, id int64) (model.Fill, error) {
+	var f model.Fill
+	err := r.db.QueryRow(ctx, `
+		SELECT id, order_id, symbol, side, price, size, realized_pnl, fee, slippage, liquidity, status, venue_trade_id, created_at
+		FROM fills
+		WHERE account = $1 AND id = $2;
+	`, account, id).Scan(&f.ID, &f.OrderID, &f.Symbol, &f.Side, &f.Price, &f.Size, &f.RealizedPnL, &f.Fee, &f.Slippage, &f.Liquidity, &f.Status, &f.VenueTradeID, &f.CreatedAt)
+	return f, err
+}
+
+// GetFillsSince lists the account's fills at or after since, oldest first.
+func (r *Repo) GetFillsSince(ctx context.Context, account string, since time.Time) ([]model.Fill, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, order_id, symbol, side, price, size, realized_pnl, fee, slippage, liquidity, status, venue_trade_id, created_at
+		FROM fills
+		WHERE account = $1 AND created_at >= $2
+		ORDER BY created_at, id;
+	`, account, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fills := make([]model.Fill, 0)
+	for rows.Next() {
+		var f model.Fill
+		if err := rows.Scan(&f.ID, &f.OrderID, &f.Symbol, &f.Side, &f.Price, &f.Size, &f.RealizedPnL, &f.Fee, &f.Slippage, &f.Liquidity, &f.Status, &f.VenueTradeID, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		fills = append(fills, f)
+	}
+	return fills, rows.Err()
+}
+
+func (r *Repo) SaveReview(ctx context.Context, account string, in model.ReviewInput) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		INSERT INTO reviews (account, fill_id, verdict, tags, notes)
+		SELECT account, id, $3, $4, $5
+		FROM fills
+		WHERE id = $2 AND account = $1;
+	`, account, in.FillID, in.Verdict, in.Tags, in.Notes)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
 }
 
 func (r *Repo) GetDerives(ctx context.Context) ([]model.StrategyDerive, error) {
-	rows, err := r.pool.Query(ctx, `
+	rows, err := r.db.Query(ctx, `
 		SELECT name, base_strategy, win_rate, pnl_ratio, condition, recommendation
 		FROM strategy_derives
 		ORDER BY created_at DESC;
@@ -84,141 +183,221 @@ func (r *Repo) GetDerives(ctx context.Context) ([]model.StrategyDerive, error) {
 	return items, rows.Err()
 }
 
-func (r *Repo) SetBias(ctx context.Context, bias string) error {
-	_, err := r.pool.Exec(ctx, `UPDATE control_state SET bias = $1, updated_at = now() WHERE id = 1;`, bias)
+func (r *Repo) SetBias(ctx context.Context, account, bias string) error {
+	_, err := r.db.Exec(ctx, `UPDATE control_state SET bias = $2, updated_at = now() WHERE account = $1;`, account, bias)
 	return err
 }
 
-func (r *Repo) GetBias(ctx context.Context) (string, error) {
+func (r *Repo) GetBias(ctx context.Context, account string) (string, error) {
 	var bias string
-	err := r.pool.QueryRow(ctx, `SELECT bias FROM control_state WHERE id = 1`).Scan(&bias)
+	err := r.db.QueryRow(ctx, `SELECT bias FROM control_state WHERE account = $1`, account).Scan(&bias)
 	return bias, err
 }
 
 func (r *Repo) SaveWalletSession(ctx context.Context, address, signature, message string) error {
-	_, err := r.pool.Exec(ctx, `
+	_, err := r.db.Exec(ctx, `
 		INSERT INTO wallet_sessions (address, connected, signature, message, updated_at)
 		VALUES ($1, true, $2, $3, now());
 	`, address, signature, message)
 	return err
 }
 
-func (r *Repo) GetLatestWalletSession(ctx context.Context) (model.WalletSession, error) {
-	row := r.pool.QueryRow(ctx, `
-		SELECT address, connected, agent_approved, agent_pub_key, updated_at
-		FROM wallet_sessions
-		ORDER BY updated_at DESC
+func (r *Repo) CreateWalletNonce(ctx context.Context, nonce string, expiresAt time.Time) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM wallet_nonces WHERE expires_at < now();`); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO wallet_nonces (nonce, expires_at)
+		VALUES ($1, $2);
+	`, nonce, expiresAt)
+	return err
+}
+
+func (r *Repo) ConsumeWalletNonce(ctx context.Context, nonce string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE wallet_nonces
+		SET used_at = now()
+		WHERE nonce = $1 AND used_at IS NULL AND expires_at > now();
+	`, nonce)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *Repo) GetLatestWalletSession(ctx context.Context, account string) (model.WalletSession, error) {
+	row := r.db.QueryRow(ctx, `
+		SELECT ws.address, ws.connected, ak.agent_address IS NOT NULL, COALESCE(ak.agent_address, ''), ak.valid_until, ws.updated_at
+		FROM wallet_sessions ws
+		LEFT JOIN agent_keys ak ON ak.account = ws.address AND ak.status = 'active'
+			AND ak.revoked_at IS NULL AND (ak.valid_until IS NULL OR ak.valid_until > now())
+		WHERE ws.address = $1
+		ORDER BY ws.updated_at DESC
 		LIMIT 1;
-	`)
+	`, account)
 	var out model.WalletSession
-	err := row.Scan(&out.Address, &out.Connected, &out.AgentApproved, &out.AgentPubKey, &out.UpdatedAt)
+	err := row.Scan(&out.Address, &out.Connected, &out.AgentApproved, &out.AgentPubKey, &out.AgentValidUntil, &out.UpdatedAt)
 	return out, err
 }
 
-func (r *Repo) ApproveAgent(ctx context.Context, agentPubKey string) error {
-	_, err := r.pool.Exec(ctx, `
+// RotateAgentKey supersedes the active agent key with a new one and marks
+// the latest wallet session approved. Call it inside WithTx.
+func (r *Repo) RotateAgentKey(ctx context.Context, account, agentAddress string, sealedKey []byte, validUntil time.Time) error {
+	if _, err := r.db.Exec(ctx, `
+		UPDATE agent_keys
+		SET status = 'superseded', revoked_at = now(), revoked_reason = 'superseded', updated_at = now()
+		WHERE account = $1 AND status = 'active';
+	`, account); err != nil {
+		return err
+	}
+	if _, err := r.db.Exec(ctx, `
+		INSERT INTO agent_keys (account, agent_address, sealed_key, status, valid_until)
+		VALUES ($1, $2, $3, 'active', $4);
+	`, account, agentAddress, sealedKey, validUntil); err != nil {
+		return err
+	}
+	if _, err := r.db.Exec(ctx, `
 		UPDATE wallet_sessions
-		SET agent_approved = true, agent_pub_key = $1, updated_at = now()
+		SET agent_approved = true, agent_pub_key = $2, updated_at = now()
 		WHERE id = (
-			SELECT id FROM wallet_sessions ORDER BY updated_at DESC LIMIT 1
+			SELECT id FROM wallet_sessions WHERE address = $1 ORDER BY updated_at DESC LIMIT 1
 		);
-	`, agentPubKey)
+	`, account, agentAddress); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RevokeAgentKey revokes the active agent key and clears the approval on the
+// account's wallet sessions. Call it inside WithTx.
+func (r *Repo) RevokeAgentKey(ctx context.Context, account, reason string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE agent_keys
+		SET status = 'revoked', revoked_at = now(), revoked_reason = $2, updated_at = now()
+		WHERE account = $1 AND status = 'active';
+	`, account, reason)
+	if err != nil {
+		return false, err
+	}
+	if _, err := r.db.Exec(ctx, `
+		UPDATE wallet_sessions SET agent_approved = false, agent_pub_key = ''
+		WHERE address = $1;
+	`, account); err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ExpireAgentKeys marks active keys past valid_until as expired and returns
+// the affected accounts.
+func (r *Repo) ExpireAgentKeys(ctx context.Context) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		UPDATE agent_keys
+		SET status = 'expired', revoked_at = now(), revoked_reason = 'expired', updated_at = now()
+		WHERE status = 'active' AND valid_until IS NOT NULL AND valid_until <= now()
+		RETURNING account;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := make([]string, 0)
+	for rows.Next() {
+		var account string
+		if err := rows.Scan(&account); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	_, err = r.db.Exec(ctx, `
+		UPDATE wallet_sessions SET agent_approved = false, agent_pub_key = ''
+		WHERE address = ANY($1);
+	`, accounts)
+	return accounts, err
+}
+
+func (r *Repo) BlockAutoTrading(ctx context.Context, account, reason string) error {
+	if _, err := r.db.Exec(ctx, `
+		UPDATE control_state SET auto_trading = false, updated_at = now() WHERE account = $1;
+	`, account); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(ctx, `
+		UPDATE strategy_runtime
+		SET runtime_status = 'blocked', last_error = $2, updated_at = now()
+		WHERE account = $1;
+	`, account, reason)
 	return err
 }
 
-func (r *Repo) StrategyStatus(ctx context.Context) (model.StrategyStatus, error) {
-	row := r.pool.QueryRow(ctx, `
+func (r *Repo) StrategyStatus(ctx context.Context, account string) (model.StrategyStatus, error) {
+	row := r.db.QueryRow(ctx, `
 		SELECT c.bias, c.auto_trading, sr.runtime_status, sr.last_signal, sr.last_error, GREATEST(c.updated_at, sr.updated_at)
 		FROM control_state c
-		JOIN strategy_runtime sr ON sr.id = 1
-		WHERE c.id = 1;
-	`)
+		JOIN strategy_runtime sr ON sr.account = c.account
+		WHERE c.account = $1;
+	`, account)
 	var out model.StrategyStatus
 	err := row.Scan(&out.Bias, &out.AutoTrading, &out.RuntimeStatus, &out.LastSignal, &out.LastError, &out.UpdatedAt)
 	return out, err
 }
 
-func (r *Repo) SetAutoTrading(ctx context.Context, enabled bool) error {
+func (r *Repo) SetAutoTrading(ctx context.Context, account string, enabled bool) error {
 	runtimeStatus := "paused"
 	if enabled {
 		runtimeStatus = "running"
 	}
-	if _, err := r.pool.Exec(ctx, `
-		UPDATE control_state SET auto_trading = $1, updated_at = now() WHERE id = 1;
-	`, enabled); err != nil {
+	if _, err := r.db.Exec(ctx, `
+		UPDATE control_state SET auto_trading = $2, updated_at = now() WHERE account = $1;
+	`, account, enabled); err != nil {
 		return err
 	}
-	_, err := r.pool.Exec(ctx, `
-		UPDATE strategy_runtime SET runtime_status = $1, updated_at = now() WHERE id = 1;
-	`, runtimeStatus)
+	_, err := r.db.Exec(ctx, `
+		UPDATE strategy_runtime SET runtime_status = $2, updated_at = now() WHERE account = $1;
+	`, account, runtimeStatus)
 	return err
 }
 
-func (r *Repo) UpdateRuntimeSignal(ctx context.Context, signal, errMsg string) error {
-	_, err := r.pool.Exec(ctx, `
+func (r *Repo) UpdateRuntimeSignal(ctx context.Context, account, signal, errMsg string) error {
+	_, err := r.db.Exec(ctx, `
 		UPDATE strategy_runtime
-		SET last_signal = $1, last_error = $2, updated_at = now()
-		WHERE id = 1;
-	`, signal, errMsg)
+		SET last_signal = $2, last_error = $3, updated_at = now()
+		WHERE account = $1;
+	`, account, signal, errMsg)
 	return err
 }
 
-func (r *Repo) CreateOrder(ctx context.Context, in model.OrderInput) (int64, error) {
-	var id int64
-	err := r.pool.QueryRow(ctx, `
-		INSERT INTO orders
-		(symbol, side, order_type, size, entry_price, stop_loss, take_profit, execution, client_tag, status)
-		VALUES
-		($1, $2, $3, $4, $5, $6, $7, $8, $9, 'open')
-		RETURNING id;
-	`, in.Symbol, in.Side, in.OrderType, in.Size, in.EntryPrice, in.StopLoss, in.TakeProfit, in.Execution, in.ClientTag).Scan(&id)
-	return id, err
-}
-
-func (r *Repo) CreateFillFromOrder(ctx context.Context, in model.OrderInput) error {
-	_, err := r.pool.Exec(ctx, `
-		INSERT INTO fills (symbol, side, price, size, realized_pnl, status, created_at)
-		VALUES ($1, $2, $3, $4, 0, 'open', now());
-	`, in.Symbol, in.Side, in.EntryPrice, in.Size)
-	return err
-}
-
-func (r *Repo) GetOrders(ctx context.Context, limit int) ([]model.Order, error) {
-	rows, err := r.pool.Query(ctx, `
-		SELECT id, symbol, side, order_type, size, entry_price, stop_loss, take_profit, status, execution, created_at
-		FROM orders
-		ORDER BY created_at DESC
-		LIMIT $1;
-	`, limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	out := make([]model.Order, 0, limit)
-	for rows.Next() {
-		var it model.Order
-		if err := rows.Scan(&it.ID, &it.Symbol, &it.Side, &it.OrderType, &it.Size, &it.EntryPrice, &it.StopLoss, &it.TakeProfit, &it.Status, &it.Execution, &it.CreatedAt); err != nil {
-			return nil, err
-		}
-		out = append(out, it)
-	}
-	return out, rows.Err()
-}
-
-func (r *Repo) IsAgentApproved(ctx context.Context) (bool, error) {
+func (r *Repo) IsAgentApproved(ctx context.Context, account string) (bool, error) {
 	var approved bool
-	err := r.pool.QueryRow(ctx, `
-		SELECT COALESCE(agent_approved, false)
-		FROM wallet_sessions
-		ORDER BY updated_at DESC
-		LIMIT 1;
-	`).Scan(&approved)
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM agent_keys
+			WHERE account = $1 AND status = 'active' AND revoked_at IS NULL
+				AND (valid_until IS NULL OR valid_until > now())
+		);
+	`, account).Scan(&approved)
 	return approved, err
 }
 
-func (r *Repo) TouchSignal(ctx context.Context, signal string) {
-	_, _ = r.pool.Exec(ctx, `
-		UPDATE strategy_runtime SET last_signal = $1, updated_at = $2 WHERE id = 1;
-	`, signal, time.Now().UTC())
+// GetActiveAgentKey returns the sealed key of the account's usable agent.
+func (r *Repo) GetActiveAgentKey(ctx context.Context, account string) (string, []byte, error) {
+	var agentAddress string
+	var sealed []byte
+	err := r.db.QueryRow(ctx, `
+		SELECT agent_address, sealed_key FROM agent_keys
+		WHERE account = $1 AND status = 'active' AND revoked_at IS NULL
+			AND (valid_until IS NULL OR valid_until > now());
+	`, account).Scan(&agentAddress, &sealed)
+	return agentAddress, sealed, err
+}
+
+func (r *Repo) TouchSignal(ctx context.Context, account, signal string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE strategy_runtime SET last_signal = $2, updated_at = $3 WHERE account = $1;
+	`, account, signal, time.Now().UTC())
+	return err
 }
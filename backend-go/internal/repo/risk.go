@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"autotrade/backend-go/internal/model"
+	"autotrade/backend-go/internal/risk"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetRiskLimits returns the account's stored limits; found is false when the
+// account has never customised them.
+func (r *Repo) GetRiskLimits(ctx context.Context, account string) (risk.Limits, bool, error) {
+	var out risk.Limits
+	err := r.db.QueryRow(ctx, `
+		SELECT max_order_notional, max_open_per_symbol, max_leverage, max_loss_at_stop_pct, allowed_symbols
+		FROM risk_limits
+		WHERE account = $1;
+	`, account).Scan(&out.MaxOrderNotional, &out.MaxOpenPerSymbol, &out.MaxLeverage, &out.MaxLossAtStopPct, &out.AllowedSymbols)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return risk.Limits{}, false, nil
+	}
+	return out, err == nil, err
+}
+
+func (r *Repo) SaveRiskLimits(ctx context.Context, account string, in risk.Limits) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO risk_limits (account, max_order_notional, max_open_per_symbol, max_leverage, max_loss_at_stop_pct, allowed_symbols, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, now())
+		ON CONFLICT (account) DO UPDATE SET
+			max_order_notional = EXCLUDED.max_order_notional,
+			max_open_per_symbol = EXCLUDED.max_open_per_symbol,
+			max_leverage = EXCLUDED.max_leverage,
+			max_loss_at_stop_pct = EXCLUDED.max_loss_at_stop_pct,
+			allowed_symbols = EXCLUDED.allowed_symbols,
+			updated_at = now();
+	`, account, in.MaxOrderNotional, in.MaxOpenPerSymbol, in.MaxLeverage, in.MaxLossAtStopPct, in.AllowedSymbols)
+	return err
+}
+
+// GetExposure sums the open positions of one execution and the unfilled
+// remainder of its working entry orders other than excludeOrderID; orders
+// are matched by model.BookExecutions, so paper includes paper-auto.
+// Stop-loss and take-profit legs only close the position they protect, so
+// they add neither count nor notional. Positions are also returned by symbol so the rules can
+// net a reducing order against them. Equity is left for the caller to fill in.
+func (r *Repo) GetExposure(ctx context.Context, account, execution string, excludeOrderID int64) (risk.Exposure, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT symbol, side, size, size * avg_entry, 1
+		FROM positions
+		WHERE account = $1 AND execution = $3 AND size > 0
+		UNION ALL
+		SELECT symbol, '', 0, (size - filled_size) * entry_price, CASE WHEN status = 'partially_filled' THEN 0 ELSE 1 END
+		FROM orders
+		WHERE account = $1 AND execution = ANY($4) AND role = 'entry'
+			AND status IN ('pending', 'open', 'partially_filled') AND id <> $2;
+	`, account, excludeOrderID, execution, model.BookExecutions(execution))
+	if err != nil {
+		return risk.Exposure{}, err
+	}
+	defer rows.Close()
+
+	out := risk.Exposure{OpenBySymbol: map[string]int{}, Positions: map[string]risk.Position{}}
+	for rows.Next() {
+		var (
+			symbol, side   string
+			size, notional float64
+			count          int
+		)
+		if err := rows.Scan(&symbol, &side, &size, &notional, &count); err != nil {
+			return risk.Exposure{}, err
+		}
+		out.OpenNotional += notional
+		out.OpenBySymbol[symbol] += count
+		if side != "" {
+			out.Positions[symbol] = risk.Position{Side: side, Size: size, Notional: notional}
+		}
+	}
+	return out, rows.Err()
+}
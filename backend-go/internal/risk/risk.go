@@ -0,0 +1,203 @@
+package risk
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Limits are the per-account thresholds. A zero value disables the rule;
+// an empty AllowedSymbols list allows every symbol.
+type Limits struct {
+	MaxOrderNotional float64  `json:"maxOrderNotional"`
+	MaxOpenPerSymbol int      `json:"maxOpenPerSymbol"`
+	MaxLeverage      float64  `json:"maxLeverage"`
+	MaxLossAtStopPct float64  `json:"maxLossAtStopPct"`
+	AllowedSymbols   []string `json:"allowedSymbols"`
+}
+
+type Order struct {
+	Symbol     string
+	Side       string
+	Size       float64
+	EntryPrice float64
+	StopLoss   float64
+}
+
+func (o Order) Notional() float64 { return o.Size * o.EntryPrice }
+
+func (o Order) LossAtStop() float64 { return o.Size * math.Abs(o.EntryPrice-o.StopLoss) }
+
+// Exposure is the account's current book before the order is placed.
+type Exposure struct {
+	Equity       float64
+	OpenNotional float64
+	OpenBySymbol map[string]int
+	Positions    map[string]Position
+}
+
+// Position is an open position; Notional is its size at the average entry.
+type Position struct {
+	Side     string
+	Size     float64
+	Notional float64
+}
+
+type Check struct {
+	Order    Order
+	Limits   Limits
+	Exposure Exposure
+}
+
+// reducing returns the opposite-side position the order nets against and how
+// much of the order closes it rather than adding exposure.
+func (c Check) reducing() (Position, float64) {
+	pos, ok := c.Exposure.Positions[c.Order.Symbol]
+	if !ok || pos.Size <= 0 || pos.Side == c.Order.Side {
+		return Position{}, 0
+	}
+	return pos, math.Min(c.Order.Size, pos.Size)
+}
+
+type Rejection struct {
+	Rule    string  `json:"rule"`
+	Message string  `json:"message"`
+	Limit   float64 `json:"limit"`
+	Actual  float64 `json:"actual"`
+}
+
+type Rule interface {
+	Name() string
+	Check(c Check) *Rejection
+}
+
+type Engine struct {
+	rules []Rule
+}
+
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+func DefaultRules() []Rule {
+	return []Rule{
+		SymbolAllowList{},
+		MaxOrderNotional{},
+		MaxOpenPerSymbol{},
+		MaxLeverage{},
+		MaxLossAtStop{},
+	}
+}
+
+// Evaluate runs every rule and returns all rejections, or nil if the order passes.
+func (e *Engine) Evaluate(c Check) []Rejection {
+	var out []Rejection
+	for _, rule := range e.rules {
+		if rej := rule.Check(c); rej != nil {
+			rej.Rule = rule.Name()
+			out = append(out, *rej)
+		}
+	}
+	return out
+}
+
+type SymbolAllowList struct{}
+
+func (SymbolAllowList) Name() string { return "symbol_allow_list" }
+
+func (SymbolAllowList) Check(c Check) *Rejection {
+	if len(c.Limits.AllowedSymbols) == 0 {
+		return nil
+	}
+	if slices.ContainsFunc(c.Limits.AllowedSymbols, func(s string) bool { return strings.EqualFold(s, c.Order.Symbol) }) {
+		return nil
+	}
+	return &Rejection{Message: fmt.Sprintf("%s is not in the allowed symbols %v", c.Order.Symbol, c.Limits.AllowedSymbols)}
+}
+
+type MaxOrderNotional struct{}
+
+func (MaxOrderNotional) Name() string { return "max_order_notional" }
+
+func (MaxOrderNotional) Check(c Check) *Rejection {
+	limit, actual := c.Limits.MaxOrderNotional, c.Order.Notional()
+	if limit <= 0 || actual <= limit {
+		return nil
+	}
+	return &Rejection{Message: fmt.Sprintf("order notional %.2f exceeds %.2f", actual, limit), Limit: limit, Actual: actual}
+}
+
+type MaxOpenPerSymbol struct{}
+
+func (MaxOpenPerSymbol) Name() string { return "max_open_per_symbol" }
+
+// Check counts the order as a new position unless it nets against an
+// opposite one, which it closes or flips without adding to the count.
+func (MaxOpenPerSymbol) Check(c Check) *Rejection {
+	limit := c.Limits.MaxOpenPerSymbol
+	if limit <= 0 {
+		return nil
+	}
+	if _, reduce := c.reducing(); reduce > 0 {
+		return nil
+	}
+	actual := c.Exposure.OpenBySymbol[c.Order.Symbol] + 1
+	if actual <= limit {
+		return nil
+	}
+	return &Rejection{
+		Message: fmt.Sprintf("%d open positions/orders on %s would exceed %d", actual, c.Order.Symbol, limit),
+		Limit:   float64(limit),
+		Actual:  float64(actual),
+	}
+}
+
+type MaxLeverage struct{}
+
+func (MaxLeverage) Name() string { return "max_leverage" }
+
+// Check lets orders that only reduce a position through, and otherwise swaps
+// the closed part of the position for the newly opened notional.
+func (MaxLeverage) Check(c Check) *Rejection {
+	limit := c.Limits.MaxLeverage
+	if limit <= 0 {
+		return nil
+	}
+	pos, reduce := c.reducing()
+	opening := c.Order.Size - reduce
+	if opening <= 0 {
+		return nil
+	}
+	if c.Exposure.Equity <= 0 {
+		return &Rejection{Message: "account has no equity", Limit: limit}
+	}
+	var closed float64
+	if reduce > 0 {
+		closed = pos.Notional * reduce / pos.Size
+	}
+	actual := (c.Exposure.OpenNotional - closed + opening*c.Order.EntryPrice) / c.Exposure.Equity
+	if actual <= limit {
+		return nil
+	}
+	return &Rejection{Message: fmt.Sprintf("aggregate leverage %.2fx exceeds %.2fx", actual, limit), Limit: limit, Actual: actual}
+}
+
+type MaxLossAtStop struct{}
+
+func (MaxLossAtStop) Name() string { return "max_loss_at_stop" }
+
+func (MaxLossAtStop) Check(c Check) *Rejection {
+	limit := c.Limits.MaxLossAtStopPct
+	if limit <= 0 {
+		return nil
+	}
+	if c.Exposure.Equity <= 0 {
+		return &Rejection{Message: "account has no equity", Limit: limit}
+	}
+	actual := c.Order.LossAtStop() / c.Exposure.Equity * 100
+	if actual <= limit {
+		return nil
+	}
+	return &Rejection{Message: fmt.Sprintf("loss at stop %.2f%% of equity exceeds %.2f%%", actual, limit), Limit: limit, Actual: actual}
+}
@@ -0,0 +1,112 @@
+package risk
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEvaluateNetsReducingOrders(t *testing.T) {
+	long := func(size, entry float64) Exposure {
+		return Exposure{
+			Equity:       1000,
+			OpenNotional: size * entry,
+			OpenBySymbol: map[string]int{"BTC": 1},
+			Positions:    map[string]Position{"BTC": {Side: "Buy", Size: size, Notional: size * entry}},
+		}
+	}
+	limits := Limits{MaxOpenPerSymbol: 1, MaxLeverage: 2}
+	tests := []struct {
+		name     string
+		order    Order
+		exposure Exposure
+		rules    []string // rules expected to reject, in evaluation order
+		leverage float64  // expected max_leverage actual when it rejects
+	}{
+		{
+			name:     "flat book opens",
+			order:    Order{Symbol: "BTC", Side: "Buy", Size: 1, EntryPrice: 1000},
+			exposure: Exposure{Equity: 1000, OpenBySymbol: map[string]int{}},
+		},
+		{
+			name:     "adding to a position counts",
+			order:    Order{Symbol: "BTC", Side: "Buy", Size: 1, EntryPrice: 1000},
+			exposure: long(1, 1000),
+			rules:    []string{"max_open_per_symbol"},
+		},
+		{
+			name:     "adding over leverage",
+			order:    Order{Symbol: "BTC", Side: "Buy", Size: 2, EntryPrice: 1000},
+			exposure: long(1, 1000),
+			rules:    []string{"max_open_per_symbol", "max_leverage"},
+			leverage: 3,
+		},
+		{
+			name:     "partial close",
+			order:    Order{Symbol: "BTC", Side: "Sell", Size: 1, EntryPrice: 1000},
+			exposure: long(3, 1000),
+		},
+		{
+			name:     "full close while over leverage",
+			order:    Order{Symbol: "BTC", Side: "Sell", Size: 3, EntryPrice: 1000},
+			exposure: long(3, 1000),
+		},
+		{
+			name:     "flip within leverage",
+			order:    Order{Symbol: "BTC", Side: "Sell", Size: 2.5, EntryPrice: 1000},
+			exposure: long(1, 1000),
+		},
+		{
+			name:     "flip over leverage",
+			order:    Order{Symbol: "BTC", Side: "Sell", Size: 4, EntryPrice: 1000},
+			exposure: long(1, 1000),
+			rules:    []string{"max_leverage"},
+			leverage: 3,
+		},
+		{
+			name:     "other symbol is not netted",
+			order:    Order{Symbol: "ETH", Side: "Sell", Size: 1, EntryPrice: 1500},
+			exposure: long(1, 1000),
+			rules:    []string{"max_leverage"},
+			leverage: 2.5,
+		},
+	}
+	engine := NewEngine(MaxOpenPerSymbol{}, MaxLeverage{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := engine.Evaluate(Check{Order: tt.order, Limits: limits, Exposure: tt.exposure})
+			if len(got) != len(tt.rules) {
+				t.Fatalf("Evaluate() = %+v, want rejections by %v", got, tt.rules)
+			}
+			for i, rej := range got {
+				if rej.Rule != tt.rules[i] {
+					t.Errorf("rejection %d rule = %q, want %q", i, rej.Rule, tt.rules[i])
+				}
+				if rej.Rule == "max_leverage" && math.Abs(rej.Actual-tt.leverage) > 1e-9 {
+					t.Errorf("leverage = %v, want %v", rej.Actual, tt.leverage)
+				}
+			}
+		})
+	}
+}
+
+func TestSizeForRisk(t *testing.T) {
+	tests := []struct {
+		name                         string
+		riskAmount, entry, stop, lot float64
+		want                         float64
+	}{
+		{"whole lots", 100, 50000, 49000, 0.001, 0.1},
+		{"rounds down", 100, 50000, 49700, 0.001, 0.333},
+		{"short stop", 100, 49000, 50000, 0.01, 0.1},
+		{"no distance", 100, 50000, 50000, 0.001, 0},
+		{"no risk", 0, 50000, 49000, 0.001, 0},
+		{"below one lot", 1, 50000, 49000, 0.01, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SizeForRisk(tt.riskAmount, tt.entry, tt.stop, tt.lot); got != tt.want {
+				t.Errorf("SizeForRisk() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
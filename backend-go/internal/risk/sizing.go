@@ -0,0 +1,28 @@
+package risk
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SizeForRisk returns the largest multiple of lot whose loss between entry and
+// stop does not exceed riskAmount.
+func SizeForRisk(riskAmount, entry, stop, lot float64) float64 {
+	perUnit := math.Abs(entry - stop)
+	if riskAmount <= 0 || perUnit == 0 || lot <= 0 {
+		return 0
+	}
+	lots := math.Floor(riskAmount/perUnit/lot + 1e-9)
+	return roundToLot(lots*lot, lot)
+}
+
+// roundToLot strips float noise so 3 lots of 0.001 come back as 0.003.
+func roundToLot(size, lot float64) float64 {
+	decimals := 0
+	if _, frac, ok := strings.Cut(strconv.FormatFloat(lot, 'f', -1, 64), "."); ok {
+		decimals = len(frac)
+	}
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(size*scale) / scale
+}
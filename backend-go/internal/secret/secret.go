@@ -0,0 +1,58 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var ErrDecrypt = errors.New("secret: decryption failed")
+
+// Box seals small secrets with AES-256-GCM. Sealed values are nonce||ciphertext.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// ParseKey decodes a hex-encoded 32-byte key.
+func ParseKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("secret: key must be 64 hex characters")
+	}
+	return key, nil
+}
+
+func NewBox(key []byte) (*Box, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+func (b *Box) Seal(plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return b.aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func (b *Box) Open(sealed, additional []byte) ([]byte, error) {
+	n := b.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, ErrDecrypt
+	}
+	out, err := b.aead.Open(nil, sealed[:n], sealed[n:], additional)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return out, nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"autotrade/backend-go/internal/model"
+	"autotrade/backend-go/internal/repo"
+)
+
+// accountState reports one execution's ledger and positions, marking every
+// open position to the latest snapshot close; positions without a mark are
+// carried at their average entry.
+func (s *Service) accountState(ctx context.Context, r *repo.Repo, account, execution string) (model.AccountState, error) {
+	balance, realized, err := r.GetBalance(ctx, account, execution)
+	if err != nil {
+		return model.AccountState{}, err
+	}
+	positions, err := r.GetPositions(ctx, account, execution)
+	if err != nil {
+		return model.AccountState{}, err
+	}
+	now := time.Now().UTC()
+	if err := s.markPositions(ctx, r, positions, now); err != nil {
+		return model.AccountState{}, err
+	}
+
+	st := model.AccountState{
+		Balance:     balance,
+		RealizedPnL: realized,
+		StaleMarks:  []string{},
+		UpdatedAt:   now,
+	}
+	for _, p := range positions {
+		st.OpenPnL += p.UnrealizedPnL
+		st.Notional += math.Abs(p.Size * p.MarkPrice)
+		if p.MarkStale {
+			st.StaleMarks = append(st.StaleMarks, p.Symbol)
+		}
+	}
+	st.Equity = st.Balance + st.OpenPnL
+	if s.cfg.MarginLeverage > 0 {
+		st.UsedMargin = st.Notional / s.cfg.MarginLeverage
+	}
+	st.FreeMargin = st.Equity - st.UsedMargin
+	if st.Equity > 0 {
+		st.Leverage = st.Notional / st.Equity
+	}
+	return st, nil
+}
+
+// markPositions fills in mark price, age and unrealised PnL. A position with
+// no snapshot, or one older than MarkStaleAfter, is flagged stale; without a
+// snapshot it is marked at its average entry.
+func (s *Service) markPositions(ctx context.Context, r *repo.Repo, positions []model.Position, now time.Time) error {
+	symbols := make([]string, 0, len(positions))
+	for _, p := range positions {
+		symbols = append(symbols, p.Symbol)
+	}
+	marks, err := r.GetMarks(ctx, symbols)
+	if err != nil {
+		return err
+	}
+	for i := range positions {
+		p := &positions[i]
+		m, ok := marks[p.Symbol]
+		if !ok {
+			p.MarkPrice, p.MarkStale = p.AvgEntry, true
+			continue
+		}
+		capturedAt := m.CapturedAt
+		p.MarkPrice = m.Price
+		p.MarkedAt = &capturedAt
+		p.MarkAgeSecs = now.Sub(m.CapturedAt).Seconds()
+		p.MarkStale = s.cfg.MarkStaleAfter > 0 && now.Sub(m.CapturedAt) > s.cfg.MarkStaleAfter
+		p.UnrealizedPnL = unrealizedPnL(*p, m.Price)
+	}
+	return nil
+}
+
+func unrealizedPnL(p model.Position, mark float64) float64 {
+	if p.Side == model.SideSell {
+		return p.Size * (p.AvgEntry - mark)
+	}
+	return p.Size * (mark - p.AvgEntry)
+}
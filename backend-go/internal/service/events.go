@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"autotrade/backend-go/internal/model"
+	"autotrade/backend-go/internal/realtime"
+	"autotrade/backend-go/internal/repo"
+	"github.com/jackc/pgx/v5"
+)
+
+// changesChannel carries the notify_change() trigger payloads.
+const changesChannel = "autotrade_changes"
+
+// resyncMargin widens the catch-up window after a reconnect to cover
+// transactions that committed after their rows' timestamps.
+const resyncMargin = 30 * time.Second
+
+// Hub is where the service publishes events for WebSocket clients.
+func (s *Service) Hub() *realtime.Hub {
+	return s.hub
+}
+
+// publish sends an event once r's transaction commits, so clients never see
+// changes that were rolled back.
+func (s *Service) publish(r *repo.Repo, topic, account string, data any) {
+	r.AfterCommit(func() {
+		s.hub.Publish(context.Background(), realtime.Event{Topic: topic, Account: account, Data: data})
+	})
+}
+
+// PruneEvents drops stored events older than WS_REPLAY_RETENTION.
+func (s *Service) PruneEvents(ctx context.Context) error {
+	n, err := s.repo.PruneEvents(ctx, time.Now().UTC().Add(-s.cfg.WSReplayRetention))
+	if err == nil && n > 0 {
+		log.Printf("pruned %d stored events", n)
+	}
+	return err
+}
+
+func (s *Service) WatchEvents(ctx context.Context, every time.Duration) {
+	runEvery(ctx, every, "event pruning", func(ctx context.Context) error {
+		return s.PruneEvents(ctx)
+	})
+}
+
+// eventStore sequences the hub's account events and serves its replays
+// from ws_events.
+type eventStore struct {
+	r *repo.Repo
+}
+
+func (st eventStore) Append(ctx context.Context, e realtime.Event) (realtime.Event, error) {
+	seq, at, err := st.r.AppendEvent(ctx, e.Account, e.Topic, e.Data)
+	if err != nil {
+		return realtime.Event{}, err
+	}
+	e.Seq, e.At = seq, at
+	return e, nil
+}
+
+func (st eventStore) LatestSeq(ctx context.Context, account string) (int64, error) {
+	return st.r.LatestEventSeq(ctx, account)
+}
+
+func (st eventStore) EventsAfter(ctx context.Context, account string, after int64, limit int) ([]realtime.Event, error) {
+	stored, err := st.r.EventsAfter(ctx, account, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]realtime.Event, 0, len(stored))
+	for _, e := range stored {
+		out = append(out, realtime.Event{Seq: e.Seq, Topic: e.Topic, Account: e.Account, Data: e.Data, At: e.CreatedAt})
+	}
+	return out, nil
+}
+
+// ListenChanges turns row changes announced by Postgres into WebSocket
+// events, so writes by the worker reach clients as well as our own. It
+// holds a dedicated connection and, after losing it, reconnects with
+// backoff and re-publishes whatever changed in between.
+func (s *Service) ListenChanges(ctx context.Context) {
+	var since time.Time
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := s.listenChanges(ctx, &since)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("change listener disconnected, retrying in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, 30*time.Second)
+	}
+}
+
+// listenChanges runs one connection until it fails. since is the database
+// time up to which changes have been published.
+func (s *Service) listenChanges(ctx context.Context, since *time.Time) error {
+	conn, err := pgx.Connect(ctx, s.cfg.DBURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+changesChannel); err != nil {
+		return err
+	}
+	var listening time.Time
+	if err := conn.QueryRow(ctx, "SELECT now()").Scan(&listening); err != nil {
+		return err
+	}
+	if !since.IsZero() {
+		changes, err := s.repo.ChangesSince(ctx, since.Add(-resyncMargin))
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			s.publishChange(ctx, c)
+		}
+		log.Printf("change listener resynced %d changes", len(changes))
+	}
+	*since = listening
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var c model.Change
+		if err := json.Unmarshal([]byte(n.Payload), &c); err != nil {
+			log.Printf("change listener: bad payload %q: %v", n.Payload, err)
+			continue
+		}
+		s.publishChange(ctx, c)
+		if c.At.After(*since) {
+			*since = c.At
+		}
+	}
+}
+
+// publishChange loads the changed row and publishes it on its topic. Rows
+// that are gone by now are skipped.
+func (s *Service) publishChange(ctx context.Context, c model.Change) {
+	var (
+		topic string
+		data  any
+		err   error
+	)
+	switch c.Table {
+	case "orders":
+		topic = realtime.TopicOrders
+		data, err = s.repo.GetOrder(ctx, c.Account, c.ID)
+	case "fills":
+		topic = realtime.TopicFills
+		data, err = s.repo.GetFill(ctx, c.Account, c.ID)
+	case "strategy_runtime", "control_state":
+		topic = realtime.TopicStrategyStatus
+		data, err = s.repo.StrategyStatus(ctx, c.Account)
+	case "market_snapshots":
+		topic = realtime.MarketTopic(c.Symbol, c.Timeframe)
+		data, err = s.repo.GetCandle(ctx, c.ID)
+	default:
+		err = fmt.Errorf("unknown table %q", c.Table)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("change listener: load %s %d: %v", c.Table, c.ID, err)
+		return
+	}
+	s.hub.Publish(ctx, realtime.Event{Topic: topic, Account: c.Account, Data: data})
+}
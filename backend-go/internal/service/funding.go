@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"autotrade/backend-go/internal/model"
+	"autotrade/backend-go/internal/repo"
+)
+
+// AccrueFunding charges the funding period that most recently started for
+// every paper position that was open at its start, at the size held then;
+// the venue settles funding on live positions itself. Each (position,
+// period) is charged once, so the sweep can run more often than the
+// interval; periods missed while the server was down are not back-filled.
+func (s *Service) AccrueFunding(ctx context.Context, now time.Time) (int, error) {
+	if s.cfg.FundingInterval <= 0 {
+		return 0, nil
+	}
+	period := now.UTC().Truncate(s.cfg.FundingInterval)
+	positions, err := s.repo.PositionsSince(ctx, model.ExecutionPaper, period)
+	if err != nil {
+		return 0, err
+	}
+	charged := 0
+	for _, p := range positions {
+		rate, mark, found, err := s.repo.GetFundingSnapshot(ctx, p.Symbol, period, s.cfg.FundingInterval)
+		if err != nil {
+			return charged, err
+		}
+		if !found || mark <= 0 {
+			continue
+		}
+		var ok bool
+		err = s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+			ok, err = chargeFunding(ctx, tx, p.Account, p.Symbol, period, rate, mark)
+			return err
+		})
+		if err != nil {
+			return charged, err
+		}
+		if ok {
+			charged++
+		}
+	}
+	return charged, nil
+}
+
+// chargeFunding applies one period's funding to a locked position: longs pay
+// and shorts receive when the rate is positive. The size held at period is
+// the current size with the fills since then replayed backwards.
+func chargeFunding(ctx context.Context, r *repo.Repo, account, symbol string, period time.Time, rate, mark float64) (bool, error) {
+	pos, err := r.LockPosition(ctx, account, model.ExecutionPaper, symbol)
+	if err != nil {
+		return false, err
+	}
+	since, err := r.NetFilledSince(ctx, account, model.BookExecutions(model.ExecutionPaper), symbol, period)
+	if err != nil {
+		return false, err
+	}
+	side, size := heldAt(pos, since)
+	if size <= sizeEpsilon {
+		return false, nil
+	}
+	amount := -size * mark * rate
+	if side == model.SideSell {
+		amount = -amount
+	}
+	id, err := r.InsertFundingPayment(ctx, account, model.FundingPayment{
+		Symbol:    symbol,
+		Period:    period,
+		Side:      side,
+		Size:      size,
+		MarkPrice: mark,
+		Rate:      rate,
+		Amount:    amount,
+	})
+	if err != nil || id == 0 {
+		return false, err
+	}
+	if _, err := r.InsertLedgerEntry(ctx, account, model.LedgerEntry{
+		Execution: model.ExecutionPaper,
+		Kind:      model.LedgerFunding,
+		Amount:    amount,
+		Symbol:    symbol,
+		RefType:   "funding_payment",
+		RefID:     &id,
+	}); err != nil {
+		return false, err
+	}
+	pos.CumulativeFunding += amount
+	return true, r.SavePosition(ctx, account, pos)
+}
+
+// heldAt returns the side and size of pos before fills netting to netSince
+// (buys positive) were applied.
+func heldAt(pos model.Position, netSince float64) (string, float64) {
+	net := pos.Size
+	if pos.Side == model.SideSell {
+		net = -net
+	}
+	net -= netSince
+	if net < 0 {
+		return model.SideSell, -net
+	}
+	return model.SideBuy, net
+}
+
+func (s *Service) WatchFunding(ctx context.Context, every time.Duration) {
+	runEvery(ctx, every, "funding accrual", func(ctx context.Context) error {
+		_, err := s.AccrueFunding(ctx, time.Now())
+		return err
+	})
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"testing"
+
+	"autotrade/backend-go/internal/model"
+)
+
+func TestHeldAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		pos      model.Position
+		netSince float64
+		side     string
+		size     float64
+	}{
+		{"no fills since", model.Position{Side: model.SideBuy, Size: 2}, 0, model.SideBuy, 2},
+		{"added after", model.Position{Side: model.SideBuy, Size: 3}, 1, model.SideBuy, 2},
+		{"opened after", model.Position{Side: model.SideSell, Size: 1}, -1, model.SideBuy, 0},
+		{"closed after", model.Position{}, -2, model.SideBuy, 2},
+		{"flipped after", model.Position{Side: model.SideSell, Size: 1}, -3, model.SideBuy, 2},
+		{"short reduced after", model.Position{Side: model.SideSell, Size: 1}, 1, model.SideSell, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			side, size := heldAt(tt.pos, tt.netSince)
+			if size != tt.size || (size > 0 && side != tt.side) {
+				t.Errorf("heldAt() = %s %v, want %s %v", side, size, tt.side, tt.size)
+			}
+		})
+	}
+}
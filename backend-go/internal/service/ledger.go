@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"autotrade/backend-go/internal/model"
+)
+
+var ledgerKinds = []string{
+	model.LedgerDeposit,
+	model.LedgerWithdrawal,
+	model.LedgerRealisedPnL,
+	model.LedgerFee,
+	model.LedgerFunding,
+	model.LedgerAdjustment,
+}
+
+// Ledger lists one execution's entries newest first. from is inclusive and
+// to exclusive; both accept RFC 3339 timestamps or plain dates.
+func (s *Service) Ledger(ctx context.Context, account, execution, from, to, kind string, limit int) ([]model.LedgerEntry, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	execution, err := parseExecution(execution)
+	if err != nil {
+		return nil, err
+	}
+	q := model.LedgerQuery{Execution: execution, Kind: strings.ToLower(strings.TrimSpace(kind)), Limit: limit}
+	if q.Kind != "" && !slices.Contains(ledgerKinds, q.Kind) {
+		return nil, ErrBadRequest("kind must be one of " + strings.Join(ledgerKinds, ", "))
+	}
+	if q.From, err = parseLedgerTime("from", from); err != nil {
+		return nil, err
+	}
+	if q.To, err = parseLedgerTime("to", to); err != nil {
+		return nil, err
+	}
+	if q.From != nil && q.To != nil && !q.To.After(*q.From) {
+		return nil, ErrBadRequest("to must be after from")
+	}
+	return s.repo.GetLedger(ctx, account, q)
+}
+
+func parseLedgerTime(name, v string) (*time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, ErrBadRequest(name + " must be an RFC 3339 timestamp or YYYY-MM-DD date")
+}
@@ -0,0 +1,475 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"autotrade/backend-go/internal/exchange"
+	"autotrade/backend-go/internal/model"
+	"autotrade/backend-go/internal/repo"
+	"github.com/jackc/pgx/v5"
+)
+
+var orderTransitions = map[string][]string{
+	model.OrderPending:         {model.OrderOpen, model.OrderCancelled, model.OrderRejected},
+	model.OrderOpen:            {model.OrderPartiallyFilled, model.OrderFilled, model.OrderCancelled, model.OrderExpired},
+	model.OrderPartiallyFilled: {model.OrderPartiallyFilled, model.OrderFilled, model.OrderCancelled, model.OrderExpired},
+}
+
+func canTransition(from, to string) bool {
+	return slices.Contains(orderTransitions[from], to)
+}
+
+func isTerminal(status string) bool {
+	return len(orderTransitions[status]) == 0
+}
+
+// CreateOrder places an order after it passes the pre-trade risk checks.
+// Market orders fill at once; limit and stop orders rest for the paper
+// matcher, and the stop-loss/take-profit become child orders on fill. A
+// non-empty idempotency key (or clientTag) makes retries safe: the same
+// request returns the original order ID with replayed set, while a different
+// request under the same key is a conflict. With riskPct instead of size the
+// order is sized like SizePosition.
+func (s *Service) CreateOrder(ctx context.Context, account string, in model.OrderInput, idempotencyKey string) (int64, bool, error) {
+	if err := s.validateOrder(&in); err != nil {
+		return 0, false, err
+	}
+	key, err := resolveIdempotencyKey(&in, idempotencyKey)
+	if err != nil {
+		return 0, false, err
+	}
+	approved, err := s.repo.IsAgentApproved(ctx, account)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, err
+	}
+	if !approved {
+		return 0, false, ErrBadRequest("agent is not approved")
+	}
+
+	var (
+		id       int64
+		replayed bool
+	)
+	err = s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+		if key != "" {
+			hash := orderRequestHash(in)
+			claimed, err := tx.ClaimIdempotencyKey(ctx, account, key, hash)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				existing, err := tx.GetIdempotencyKey(ctx, account, key)
+				if err != nil {
+					return err
+				}
+				if existing.RequestHash != hash {
+					return ErrConflict("idempotency key was already used for a different order")
+				}
+				if existing.OrderID == nil {
+					return ErrConflict("an order with this idempotency key is still being placed")
+				}
+				id, replayed = *existing.OrderID, true
+				return nil
+			}
+		}
+		if in.RiskPct > 0 {
+			quote, err := s.sizePosition(ctx, tx, account, model.SizeInput{
+				Execution:  in.Execution,
+				Symbol:     in.Symbol,
+				Side:       in.Side,
+				EntryPrice: in.EntryPrice,
+				StopLoss:   in.StopLoss,
+				RiskPct:    in.RiskPct,
+			})
+			if err != nil {
+				return err
+			}
+			in.Size = quote.Size
+		}
+		if err := s.checkRisk(ctx, tx, account, in, 0); err != nil {
+			return err
+		}
+		var err error
+		if id, err = tx.CreateOrder(ctx, account, in); err != nil {
+			return err
+		}
+		if key != "" {
+			if err := tx.AttachIdempotencyKey(ctx, account, key, id); err != nil {
+				return err
+			}
+		}
+		if in.Execution == model.ExecutionLive {
+			// Sent to the venue once the order is committed, below.
+			return tx.TouchSignal(ctx, account, fmt.Sprintf("%s %s %.4f", in.Symbol, in.Side, in.Size))
+		}
+		order, err := tx.GetOrder(ctx, account, id)
+		if err != nil {
+			return err
+		}
+		if _, err := (paperExecutor{s: s, r: tx}).PlaceOrder(ctx, exchange.OrderRequest{Account: account, Order: order}); err != nil {
+			return err
+		}
+		return tx.TouchSignal(ctx, account, fmt.Sprintf("%s %s %.4f", in.Symbol, in.Side, in.Size))
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	if in.Execution == model.ExecutionLive && !replayed {
+		if err := s.submitLive(ctx, account, id); err != nil {
+			return 0, false, err
+		}
+	}
+	return id, replayed, nil
+}
+
+// submitLive sends a committed pending order to the venue, with its
+// stop-loss and take-profit as pending child orders placed in the same
+// bracket. A venue rejection rejects the order and its legs; a transport
+// failure leaves them pending for reconciliation since the venue may still
+// have accepted them. A fill reported with the placement is recorded at once.
+func (s *Service) submitLive(ctx context.Context, account string, id int64) error {
+	order, err := s.loadOrder(ctx, account, id)
+	if err != nil {
+		return err
+	}
+	var legs []model.Order
+	if order.Role == model.RoleEntry {
+		err := s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+			var err error
+			legs, err = s.createBracket(ctx, tx, account, order, order.Size)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	reject := func(cause error) error {
+		err := s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+			for i := range legs {
+				if err := s.advanceOrder(ctx, tx, account, &legs[i], model.OrderRejected, 0, map[string]any{"error": cause.Error()}); err != nil {
+					return err
+				}
+			}
+			return s.advanceOrder(ctx, tx, account, &order, model.OrderRejected, 0, map[string]any{"error": cause.Error()})
+		})
+		if err != nil {
+			return err
+		}
+		return cause
+	}
+	key, err := s.agentSigner(ctx, account)
+	if err != nil {
+		return reject(err)
+	}
+	defer key.Zero()
+	placement, err := s.live.PlaceOrder(ctx, exchange.OrderRequest{Account: account, Signer: key, Order: order, Legs: legs})
+	if errors.Is(err, exchange.ErrRejected) {
+		return reject(ErrBadRequest(err.Error()))
+	}
+	if err != nil {
+		return err
+	}
+	return s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+		if err := tx.SetVenueOrderID(ctx, account, id, placement.VenueOrderID); err != nil {
+			return err
+		}
+		if err := s.advanceOrder(ctx, tx, account, &order, model.OrderOpen, 0, map[string]any{"venueOrderId": placement.VenueOrderID, "venueStatus": placement.Status}); err != nil {
+			return err
+		}
+		// Legs the venue holds back until the entry fills stay pending;
+		// reconciliation finds them by client id once they rest.
+		for i, venueID := range placement.LegOrderIDs {
+			if i == len(legs) || venueID == "" {
+				continue
+			}
+			if err := tx.SetVenueOrderID(ctx, account, legs[i].ID, venueID); err != nil {
+				return err
+			}
+			if err := s.advanceOrder(ctx, tx, account, &legs[i], model.OrderOpen, 0, map[string]any{"venueOrderId": venueID}); err != nil {
+				return err
+			}
+		}
+		switch placement.Status {
+		case model.OrderFilled, model.OrderPartiallyFilled:
+			return s.fillPlacement(ctx, tx, account, &order, placement)
+		}
+		return nil
+	})
+}
+
+// fillPlacement records the fill a live order reported on placement. The
+// order is partially filled when the venue filled less than its size, e.g.
+// an IOC whose rest was cancelled; reconciliation settles the remainder.
+func (s *Service) fillPlacement(ctx context.Context, r *repo.Repo, account string, order *model.Order, placement exchange.Placement) error {
+	size := placement.FilledSize
+	if size <= 0 || size > order.Size {
+		size = order.Size
+	}
+	price := placement.AvgPrice
+	if price <= 0 {
+		price = order.EntryPrice
+	}
+	fill, err := s.recordFill(ctx, r, account, model.ExecutionLive, model.Fill{
+		OrderID: &order.ID,
+		Symbol:  order.Symbol,
+		Side:    order.Side,
+		Price:   price,
+		Size:    size,
+	})
+	if err != nil {
+		return err
+	}
+	to := model.OrderFilled
+	if order.Size-size > sizeEpsilon {
+		to = model.OrderPartiallyFilled
+	}
+	if err := s.advanceOrder(ctx, r, account, order, to, size, map[string]any{"price": fill.Price, "size": fill.Size, "fillId": fill.ID}); err != nil {
+		return err
+	}
+	order.FilledSize = size
+	return nil
+}
+
+// validateOrder normalises symbol and side in place and reports every
+// invalid field at once.
+func (s *Service) validateOrder(in *model.OrderInput) error {
+	fields := FieldErrors{}
+	in.Symbol = strings.ToUpper(strings.TrimSpace(in.Symbol))
+	if in.Symbol == "" {
+		fields.add("symbol", "is required")
+	}
+	side, ok := normalizeSide(in.Side)
+	if !ok {
+		fields.add("side", "must be Buy or Sell (long/short accepted)")
+	}
+	in.Side = side
+	in.Execution = strings.ToLower(strings.TrimSpace(in.Execution))
+	switch in.Execution {
+	case "":
+		in.Execution = model.ExecutionPaper
+	case model.ExecutionPaper, model.ExecutionLive:
+	default:
+		fields.add("execution", "must be paper or live")
+	}
+	if in.Execution == model.ExecutionLive && s.live == nil {
+		fields.add("execution", "live execution is not configured")
+	}
+	in.OrderType = strings.ToLower(strings.TrimSpace(in.OrderType))
+	switch in.OrderType {
+	case "":
+		in.OrderType = model.OrderTypeMarket
+	case model.OrderTypeMarket, model.OrderTypeLimit, model.OrderTypeStop:
+	default:
+		fields.add("orderType", "must be market, limit or stop")
+	}
+	switch {
+	case in.RiskPct != 0 && in.Size != 0:
+		fields.add("size", "give either size or riskPct, not both")
+	case in.RiskPct != 0:
+		validateRiskSpec(in.RiskPct, 0, fields)
+	case in.Size <= 0:
+		fields.add("size", "must be positive")
+	}
+	if in.EntryPrice <= 0 {
+		fields.add("entryPrice", "must be positive")
+	}
+	if in.StopLoss <= 0 {
+		fields.add("stopLoss", "is required")
+	}
+	if in.TakeProfit <= 0 {
+		fields.add("takeProfit", "is required")
+	}
+	s.validateBracket(in.Side, in.EntryPrice, in.StopLoss, in.TakeProfit, fields)
+	return fields.err()
+}
+
+func resolveIdempotencyKey(in *model.OrderInput, header string) (string, error) {
+	header = strings.TrimSpace(header)
+	tag := strings.TrimSpace(in.ClientTag)
+	switch {
+	case header != "" && tag != "" && header != tag:
+		return "", ErrBadRequest("Idempotency-Key and clientTag differ")
+	case header != "":
+		in.ClientTag = header
+		return header, nil
+	default:
+		in.ClientTag = tag
+		return tag, nil
+	}
+}
+
+// orderRequestHash fingerprints the normalised order body, ignoring the key itself.
+func orderRequestHash(in model.OrderInput) string {
+	in.ClientTag = ""
+	body, _ := json.Marshal(in)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Service) Orders(ctx context.Context, account string, limit int) ([]model.Order, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.repo.GetOrders(ctx, account, limit)
+}
+
+func (s *Service) Order(ctx context.Context, account string, id int64) (model.Order, []model.OrderEvent, error) {
+	order, err := s.loadOrder(ctx, account, id)
+	if err != nil {
+		return model.Order{}, nil, err
+	}
+	events, err := s.repo.GetOrderEvents(ctx, account, id)
+	if err != nil {
+		return model.Order{}, nil, err
+	}
+	return order, events, nil
+}
+
+func (s *Service) CancelOrder(ctx context.Context, account string, id int64) (model.Order, error) {
+	order, err := s.loadOrder(ctx, account, id)
+	if err != nil {
+		return model.Order{}, err
+	}
+	if !canTransition(order.Status, model.OrderCancelled) {
+		return model.Order{}, ErrConflict(fmt.Sprintf("cannot move order from %s to %s", order.Status, model.OrderCancelled))
+	}
+	if order.Execution == model.ExecutionLive && order.VenueOrderID != "" {
+		if err := s.cancelLive(ctx, account, order); err != nil {
+			return model.Order{}, err
+		}
+	}
+	err = s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+		return s.advanceOrder(ctx, tx, account, &order, model.OrderCancelled, 0, map[string]any{"reason": "user"})
+	})
+	if err != nil {
+		return model.Order{}, err
+	}
+	return s.loadOrder(ctx, account, id)
+}
+
+func (s *Service) cancelLive(ctx context.Context, account string, order model.Order) error {
+	if s.live == nil {
+		return ErrBadRequest("live execution is not configured")
+	}
+	key, err := s.agentSigner(ctx, account)
+	if err != nil {
+		return err
+	}
+	defer key.Zero()
+	err = s.live.CancelOrder(ctx, exchange.OrderRequest{Account: account, Signer: key, Order: order})
+	if errors.Is(err, exchange.ErrRejected) {
+		return ErrConflict(err.Error())
+	}
+	return err
+}
+
+func (s *Service) AmendOrder(ctx context.Context, account string, id int64, in model.OrderAmend) (model.Order, error) {
+	if in.EntryPrice == nil && in.Size == nil && in.StopLoss == nil && in.TakeProfit == nil {
+		return model.Order{}, ErrBadRequest("nothing to amend")
+	}
+	for name, v := range map[string]*float64{"entryPrice": in.EntryPrice, "size": in.Size, "stopLoss": in.StopLoss, "takeProfit": in.TakeProfit} {
+		if v != nil && *v <= 0 {
+			return model.Order{}, ErrBadRequest(name + " must be positive")
+		}
+	}
+	order, err := s.loadOrder(ctx, account, id)
+	if err != nil {
+		return model.Order{}, err
+	}
+	if isTerminal(order.Status) {
+		return model.Order{}, ErrConflict(fmt.Sprintf("cannot amend %s order", order.Status))
+	}
+	if order.Execution == model.ExecutionLive {
+		return model.Order{}, ErrBadRequest("live orders cannot be amended; cancel and place a new one")
+	}
+	if in.Size != nil && *in.Size < order.FilledSize {
+		return model.Order{}, ErrBadRequest("size cannot be below the filled size")
+	}
+	if in.EntryPrice != nil && order.Status == model.OrderPartiallyFilled {
+		return model.Order{}, ErrConflict("cannot reprice a partially filled order")
+	}
+	entry, stopLoss, takeProfit := order.EntryPrice, order.StopLoss, order.TakeProfit
+	if in.EntryPrice != nil {
+		entry = *in.EntryPrice
+	}
+	if in.StopLoss != nil {
+		stopLoss = *in.StopLoss
+	}
+	if in.TakeProfit != nil {
+		takeProfit = *in.TakeProfit
+	}
+	fields := FieldErrors{}
+	side, _ := normalizeSide(order.Side)
+	s.validateBracket(side, entry, stopLoss, takeProfit, fields)
+	if err := fields.err(); err != nil {
+		return model.Order{}, err
+	}
+	size := order.Size
+	if in.Size != nil {
+		size = *in.Size
+	}
+	err = s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+		// Bracket legs only reduce the position, so only entries are gated.
+		if order.Role == model.RoleEntry {
+			amended := model.OrderInput{
+				Execution:  order.Execution,
+				Symbol:     order.Symbol,
+				Side:       side,
+				Size:       size - order.FilledSize,
+				EntryPrice: entry,
+				StopLoss:   stopLoss,
+				TakeProfit: takeProfit,
+			}
+			if err := s.checkRisk(ctx, tx, account, amended, order.ID); err != nil {
+				return err
+			}
+		}
+		ok, err := tx.AmendOrder(ctx, account, id, order.Status, in)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrConflict("order changed while amending, retry")
+		}
+		return nil
+	})
+	if err != nil {
+		return model.Order{}, err
+	}
+	return s.loadOrder(ctx, account, id)
+}
+
+func (s *Service) loadOrder(ctx context.Context, account string, id int64) (model.Order, error) {
+	order, err := s.repo.GetOrder(ctx, account, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.Order{}, ErrNotFound("order not found")
+		}
+		return model.Order{}, err
+	}
+	return order, nil
+}
+
+// advanceOrder applies a state machine transition, failing with a conflict if
+// it is illegal or the stored status moved underneath us.
+func (s *Service) advanceOrder(ctx context.Context, r *repo.Repo, account string, order *model.Order, to string, filledSize float64, detail map[string]any) error {
+	if !canTransition(order.Status, to) {
+		return ErrConflict(fmt.Sprintf("cannot move order from %s to %s", order.Status, to))
+	}
+	ok, err := r.TransitionOrder(ctx, account, order.ID, order.Status, to, filledSize, detail)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrConflict("order changed concurrently, retry")
+	}
+	order.Status = to
+	return nil
+}
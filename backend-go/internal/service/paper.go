@@ -0,0 +1,382 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"autotrade/backend-go/internal/exchange"
+	"autotrade/backend-go/internal/model"
+	"autotrade/backend-go/internal/paper"
+	"autotrade/backend-go/internal/repo"
+	"github.com/jackc/pgx/v5"
+)
+
+// marketCandle is the latest fresh candle for symbol. Without one, a
+// synthetic candle at fallback stands in so market orders still fill.
+func (s *Service) marketCandle(ctx context.Context, r *repo.Repo, symbol string, fallback float64) (model.Candle, error) {
+	c, err := r.GetLatestCandle(ctx, symbol, s.cfg.PaperMatchTimeframe)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return model.Candle{}, err
+	}
+	stale := s.cfg.MarkStaleAfter > 0 && time.Since(c.CapturedAt) > s.cfg.MarkStaleAfter
+	if err != nil || stale || c.Close <= 0 {
+		return model.Candle{Symbol: symbol, Open: fallback, High: fallback, Low: fallback, Close: fallback}, nil
+	}
+	return c, nil
+}
+
+// fillOrder fills the rest of a working order at price, applying fees and
+// slippage for the candle it executes in. Bracket legs are reduce-only: they
+// shrink to the open position and are cancelled if it is already flat. A
+// filled entry spawns its legs; a filled leg cancels its sibling.
+func (s *Service) fillOrder(ctx context.Context, r *repo.Repo, account string, order *model.Order, price float64, c model.Candle) error {
+	size := order.Size - order.FilledSize
+	if order.Role != model.RoleEntry {
+		pos, err := r.LockPosition(ctx, account, bookOf(order.Execution), order.Symbol)
+		if err != nil {
+			return err
+		}
+		if pos.Size <= sizeEpsilon || pos.Side == order.Side {
+			if err := s.advanceOrder(ctx, r, account, order, model.OrderCancelled, 0, map[string]any{"reason": "position closed"}); err != nil {
+				return err
+			}
+			return s.cancelSiblings(ctx, r, account, *order, "position closed")
+		}
+		if pos.Size < size {
+			size = pos.Size
+		}
+	}
+	exec := s.costs.Apply(order.Symbol, order.Side, order.OrderType != model.OrderTypeLimit, price, size, c)
+	fill, err := s.recordFill(ctx, r, account, bookOf(order.Execution), model.Fill{
+		OrderID:   &order.ID,
+		Symbol:    order.Symbol,
+		Side:      order.Side,
+		Price:     exec.Price,
+		Size:      size,
+		Fee:       exec.Fee,
+		Slippage:  exec.Slippage,
+		Liquidity: exec.Liquidity,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.advanceOrder(ctx, r, account, order, model.OrderFilled, order.FilledSize+size, map[string]any{"price": fill.Price, "size": fill.Size, "fillId": fill.ID, "fee": fill.Fee, "slippage": fill.Slippage}); err != nil {
+		return err
+	}
+	order.FilledSize += size
+	if order.Role == model.RoleEntry {
+		return s.spawnBracket(ctx, r, account, *order)
+	}
+	return s.cancelSiblings(ctx, r, account, *order, "oco")
+}
+
+func (s *Service) spawnBracket(ctx context.Context, r *repo.Repo, account string, entry model.Order) error {
+	legs, err := s.createBracket(ctx, r, account, entry, entry.FilledSize)
+	if err != nil {
+		return err
+	}
+	for i := range legs {
+		if err := s.advanceOrder(ctx, r, account, &legs[i], model.OrderOpen, 0, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createBracket adds entry's stop-loss and take-profit as pending child
+// orders of size and returns them.
+func (s *Service) createBracket(ctx context.Context, r *repo.Repo, account string, entry model.Order, size float64) ([]model.Order, error) {
+	side := model.SideSell
+	if entry.Side == model.SideSell {
+		side = model.SideBuy
+	}
+	legs := []struct {
+		role, orderType string
+		price           float64
+	}{
+		{model.RoleStopLoss, model.OrderTypeStop, entry.StopLoss},
+		{model.RoleTakeProfit, model.OrderTypeLimit, entry.TakeProfit},
+	}
+	var out []model.Order
+	for _, leg := range legs {
+		if leg.price <= 0 {
+			continue
+		}
+		id, err := r.CreateChildOrder(ctx, account, entry, leg.role, side, leg.orderType, size, leg.price)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, model.Order{
+			ID:         id,
+			Symbol:     entry.Symbol,
+			Side:       side,
+			OrderType:  leg.orderType,
+			Size:       size,
+			EntryPrice: leg.price,
+			Status:     model.OrderPending,
+			Execution:  entry.Execution,
+			ParentID:   &entry.ID,
+			Role:       leg.role,
+		})
+	}
+	return out, nil
+}
+
+func (s *Service) cancelSiblings(ctx context.Context, r *repo.Repo, account string, leg model.Order, reason string) error {
+	if leg.ParentID == nil {
+		return nil
+	}
+	siblings, err := r.GetSiblingOrders(ctx, account, *leg.ParentID, leg.ID)
+	if err != nil {
+		return err
+	}
+	for i := range siblings {
+		if err := s.advanceOrder(ctx, r, account, &siblings[i], model.OrderCancelled, 0, map[string]any{"reason": reason, "by": leg.ID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// paperExecutor is the exchange.Executor for simulated orders. It works on r
+// so placement joins the caller's transaction; venue order ids are the local
+// order ids.
+type paperExecutor struct {
+	s *Service
+	r *repo.Repo
+}
+
+var _ exchange.Executor = paperExecutor{}
+
+// PlaceOrder opens the order and fills market orders at once; limit and
+// stop orders rest for MatchPaperOrders.
+func (e paperExecutor) PlaceOrder(ctx context.Context, req exchange.OrderRequest) (exchange.Placement, error) {
+	order := req.Order
+	if err := e.s.advanceOrder(ctx, e.r, req.Account, &order, model.OrderOpen, 0, nil); err != nil {
+		return exchange.Placement{}, err
+	}
+	placement := exchange.Placement{VenueOrderID: strconv.FormatInt(order.ID, 10)}
+	if order.OrderType == model.OrderTypeMarket {
+		c, err := e.s.marketCandle(ctx, e.r, order.Symbol, order.EntryPrice)
+		if err != nil {
+			return exchange.Placement{}, err
+		}
+		if err := e.s.fillOrder(ctx, e.r, req.Account, &order, c.Close, c); err != nil {
+			return exchange.Placement{}, err
+		}
+		placement.AvgPrice = c.Close
+	}
+	placement.Status = order.Status
+	placement.FilledSize = order.FilledSize
+	return placement, nil
+}
+
+// CancelOrder has nothing to withdraw: resting paper orders live only in
+// the orders table, which the caller updates.
+func (e paperExecutor) CancelOrder(ctx context.Context, req exchange.OrderRequest) error {
+	return nil
+}
+
+func (e paperExecutor) OrderStatus(ctx context.Context, account, venueOrderID string) (exchange.OrderState, error) {
+	id, err := strconv.ParseInt(venueOrderID, 10, 64)
+	if err != nil {
+		return exchange.OrderState{}, fmt.Errorf("paper order id %q: %w", venueOrderID, err)
+	}
+	order, err := e.r.GetOrder(ctx, account, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return exchange.OrderState{}, fmt.Errorf("paper: %w: %s", exchange.ErrNotFound, venueOrderID)
+	}
+	if err != nil {
+		return exchange.OrderState{}, err
+	}
+	return exchange.OrderState{VenueOrderID: venueOrderID, Symbol: order.Symbol, Status: order.Status, FilledSize: order.FilledSize}, nil
+}
+
+func (e paperExecutor) OrderStatusByClientID(ctx context.Context, account string, orderID int64) (exchange.OrderState, error) {
+	return e.OrderStatus(ctx, account, strconv.FormatInt(orderID, 10))
+}
+
+func (e paperExecutor) Fills(ctx context.Context, account string, since time.Time) ([]exchange.Fill, error) {
+	fills, err := e.r.GetFillsSince(ctx, account, since)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]exchange.Fill, 0, len(fills))
+	for _, f := range fills {
+		var venueOrderID string
+		if f.OrderID != nil {
+			venueOrderID = strconv.FormatInt(*f.OrderID, 10)
+		}
+		out = append(out, exchange.Fill{
+			VenueOrderID: venueOrderID,
+			TradeID:      strconv.FormatInt(f.ID, 10),
+			Symbol:       f.Symbol,
+			Side:         f.Side,
+			Price:        f.Price,
+			Size:         f.Size,
+			Fee:          f.Fee,
+			Time:         f.CreatedAt,
+		})
+	}
+	return out, nil
+}
+
+func (e paperExecutor) OpenOrders(ctx context.Context, account string) ([]exchange.OrderState, error) {
+	orders, err := e.r.GetWorkingOrders(ctx, account, model.BookExecutions(model.ExecutionPaper))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]exchange.OrderState, 0, len(orders))
+	for _, o := range orders {
+		if o.Status == model.OrderPending {
+			continue
+		}
+		out = append(out, exchange.OrderState{VenueOrderID: strconv.FormatInt(o.ID, 10), Symbol: o.Symbol, Status: o.Status, FilledSize: o.FilledSize})
+	}
+	return out, nil
+}
+
+func (e paperExecutor) Positions(ctx context.Context, account string) ([]exchange.PositionState, error) {
+	positions, err := e.r.GetPositions(ctx, account, model.ExecutionPaper)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]exchange.PositionState, 0, len(positions))
+	for _, p := range positions {
+		out = append(out, exchange.PositionState{Symbol: p.Symbol, Size: signedSize(p), AvgEntry: p.AvgEntry})
+	}
+	return out, nil
+}
+
+// AdmitAutoOrders runs the auto-trader's pending orders through the same
+// validation and risk checks as API orders. Orders that pass are placed on
+// the paper executor; the rest are rejected with the reason in their events.
+func (s *Service) AdmitAutoOrders(ctx context.Context) (int, error) {
+	orders, err := s.repo.GetPendingOrders(ctx, model.ExecutionPaperAuto)
+	if err != nil {
+		return 0, err
+	}
+	admitted := 0
+	for i := range orders {
+		o := &orders[i]
+		err := s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+			return s.admitAutoOrder(ctx, tx, o.Account, &o.Order)
+		})
+		if IsConflict(err) {
+			continue
+		}
+		if err != nil {
+			return admitted, err
+		}
+		if o.Status != model.OrderRejected {
+			admitted++
+		}
+	}
+	return admitted, nil
+}
+
+func (s *Service) admitAutoOrder(ctx context.Context, r *repo.Repo, account string, order *model.Order) error {
+	in := model.OrderInput{
+		Symbol:     order.Symbol,
+		Side:       order.Side,
+		OrderType:  order.OrderType,
+		Size:       order.Size,
+		EntryPrice: order.EntryPrice,
+		StopLoss:   order.StopLoss,
+		TakeProfit: order.TakeProfit,
+	}
+	err := s.validateOrder(&in)
+	if err == nil && (in.Symbol != order.Symbol || in.Side != order.Side || in.OrderType != order.OrderType) {
+		// Stored as written; positions and matching expect canonical values.
+		err = FieldErrors{"order": "symbol, side and orderType must be in canonical form"}.err()
+	}
+	if err == nil {
+		// The order is already in the book as pending; leave it out.
+		err = s.checkRisk(ctx, r, account, in, order.ID)
+	}
+	if fields, ok := ValidationFields(err); ok {
+		return s.advanceOrder(ctx, r, account, order, model.OrderRejected, 0, map[string]any{"reason": "validation", "fields": fields})
+	}
+	if rejections, ok := RiskRejections(err); ok {
+		return s.advanceOrder(ctx, r, account, order, model.OrderRejected, 0, map[string]any{"reason": "risk", "rejections": rejections})
+	}
+	if err != nil {
+		return err
+	}
+	placement, err := (paperExecutor{s: s, r: r}).PlaceOrder(ctx, exchange.OrderRequest{Account: account, Order: *order})
+	if err != nil {
+		return err
+	}
+	order.Status = placement.Status
+	return nil
+}
+
+// MatchPaperOrders admits pending auto-trader orders, then runs new candles
+// through the resting paper orders, advancing the cursor candle by candle.
+func (s *Service) MatchPaperOrders(ctx context.Context) (int, error) {
+	if _, err := s.AdmitAutoOrders(ctx); err != nil {
+		return 0, err
+	}
+	timeframe := s.cfg.PaperMatchTimeframe
+	cursor, err := s.repo.PaperCursor(ctx, timeframe)
+	if err != nil {
+		return 0, err
+	}
+	candles, err := s.repo.GetCandlesAfter(ctx, timeframe, cursor, 500)
+	if err != nil {
+		return 0, err
+	}
+	filled := 0
+	for _, c := range candles {
+		if c.High > 0 && c.Low > 0 {
+			n, err := s.matchCandle(ctx, c)
+			filled += n
+			if err != nil {
+				return filled, err
+			}
+		}
+		if err := s.repo.SavePaperCursor(ctx, timeframe, c.ID); err != nil {
+			return filled, err
+		}
+	}
+	return filled, nil
+}
+
+func (s *Service) matchCandle(ctx context.Context, c model.Candle) (int, error) {
+	orders, err := s.repo.GetWorkingPaperOrders(ctx, c.Symbol, c.CapturedAt.Truncate(s.candle))
+	if err != nil {
+		return 0, err
+	}
+	sort.SliceStable(orders, func(i, j int) bool { return paper.Priority(orders[i].Order) < paper.Priority(orders[j].Order) })
+	filled := 0
+	for i := range orders {
+		o := &orders[i]
+		price, ok := paper.Match(o.Order, c, s.candle)
+		if !ok {
+			continue
+		}
+		err := s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+			return s.fillOrder(ctx, tx, o.Account, &o.Order, price, c)
+		})
+		if IsConflict(err) {
+			// A sibling fill or user action already moved this order.
+			continue
+		}
+		if err != nil {
+			return filled, err
+		}
+		filled++
+	}
+	return filled, nil
+}
+
+func (s *Service) WatchPaperOrders(ctx context.Context, every time.Duration) {
+	runEvery(ctx, every, "paper matching", func(ctx context.Context) error {
+		_, err := s.MatchPaperOrders(ctx)
+		return err
+	})
+}
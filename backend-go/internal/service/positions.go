@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"autotrade/backend-go/internal/model"
+	"autotrade/backend-go/internal/realtime"
+	"autotrade/backend-go/internal/repo"
+	"github.com/jackc/pgx/v5"
+)
+
+// sizeEpsilon treats float residue left after netting as flat.
+const sizeEpsilon = 1e-9
+
+// Positions lists open positions in one execution, or in both when
+// execution is empty.
+func (s *Service) Positions(ctx context.Context, account, execution string) ([]model.Position, error) {
+	if execution != "" {
+		var err error
+		if execution, err = parseExecution(execution); err != nil {
+			return nil, err
+		}
+	}
+	positions, err := s.repo.GetPositions(ctx, account, execution)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.markPositions(ctx, s.repo, positions, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return positions, nil
+}
+
+func (s *Service) Position(ctx context.Context, account, execution, symbol string) (model.Position, error) {
+	execution, err := parseExecution(execution)
+	if err != nil {
+		return model.Position{}, err
+	}
+	p, err := s.repo.GetPosition(ctx, account, execution, strings.ToUpper(strings.TrimSpace(symbol)))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.Position{}, ErrNotFound("no position for symbol")
+		}
+		return model.Position{}, err
+	}
+	if p.Size <= sizeEpsilon {
+		return p, nil
+	}
+	marked := []model.Position{p}
+	if err := s.markPositions(ctx, s.repo, marked, time.Now().UTC()); err != nil {
+		return model.Position{}, err
+	}
+	return marked[0], nil
+}
+
+// recordFill nets the fill into the symbol's position in execution and
+// stores it with the PnL it realised, posting that PnL and any fee to the
+// execution's ledger. Fills that reduce or close a position are stored as
+// closed.
+func (s *Service) recordFill(ctx context.Context, r *repo.Repo, account, execution string, f model.Fill) (model.Fill, error) {
+	pos, err := r.LockPosition(ctx, account, execution, f.Symbol)
+	if err != nil {
+		return model.Fill{}, err
+	}
+	now := time.Now().UTC()
+	next, realized := applyFill(pos, f.Side, f.Price, f.Size, now)
+	if err := r.SavePosition(ctx, account, next); err != nil {
+		return model.Fill{}, err
+	}
+	s.publish(r, realtime.TopicPositions, account, next)
+	f.RealizedPnL = realized
+	f.Status = "open"
+	if pos.Size > 0 && pos.Side != f.Side {
+		f.Status = "closed"
+	}
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = now
+	}
+	if f.ID, err = r.CreateFill(ctx, account, f); err != nil {
+		return model.Fill{}, err
+	}
+	fillID := f.ID
+	entries := []model.LedgerEntry{
+		{Kind: model.LedgerRealisedPnL, Amount: realized},
+		{Kind: model.LedgerFee, Amount: -f.Fee},
+	}
+	for _, e := range entries {
+		if e.Amount == 0 {
+			continue
+		}
+		e.Execution, e.Symbol, e.RefType, e.RefID = execution, f.Symbol, "fill", &fillID
+		if _, err := r.InsertLedgerEntry(ctx, account, e); err != nil {
+			return model.Fill{}, err
+		}
+	}
+	return f, nil
+}
+
+// applyFill uses average-cost accounting: fills on the same side blend into
+// the average entry, opposite fills realise PnL against it and may flip the
+// position, opening the remainder at the fill price.
+func applyFill(p model.Position, side string, price, size float64, now time.Time) (model.Position, float64) {
+	if p.Size <= sizeEpsilon || p.Side == side {
+		if p.Size <= sizeEpsilon {
+			p.Size, p.AvgEntry, p.CumulativeFunding, p.OpenedAt = 0, 0, 0, &now
+		}
+		p.AvgEntry = (p.Size*p.AvgEntry + size*price) / (p.Size + size)
+		p.Size += size
+		p.Side = side
+		return p, 0
+	}
+
+	closed := math.Min(p.Size, size)
+	direction := 1.0
+	if p.Side == model.SideSell {
+		direction = -1
+	}
+	realized := closed * (price - p.AvgEntry) * direction
+	p.RealizedPnL += realized
+	p.Size -= closed
+	remaining := size - closed
+
+	switch {
+	case remaining > sizeEpsilon:
+		p.Side, p.Size, p.AvgEntry, p.CumulativeFunding, p.OpenedAt = side, remaining, price, 0, &now
+	case p.Size <= sizeEpsilon:
+		p.Side, p.Size, p.AvgEntry, p.CumulativeFunding, p.OpenedAt = "", 0, 0, 0, nil
+	}
+	return p, realized
+}
+
+// bookOf is the execution whose positions and ledger an order's fills settle
+// in: live, or paper for simulated and auto-trader orders.
+func bookOf(execution string) string {
+	if execution == model.ExecutionLive {
+		return model.ExecutionLive
+	}
+	return model.ExecutionPaper
+}
+
+// parseExecution validates an execution query parameter, defaulting to paper.
+func parseExecution(v string) (string, error) {
+	switch v = strings.ToLower(strings.TrimSpace(v)); v {
+	case "":
+		return model.ExecutionPaper, nil
+	case model.ExecutionPaper, model.ExecutionLive:
+		return v, nil
+	}
+	return "", ErrBadRequest("execution must be paper or live")
+}
+
+// signedSize is the position size, negative when short.
+func signedSize(p model.Position) float64 {
+	if p.Side == model.SideSell {
+		return -p.Size
+	}
+	return p.Size
+}
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"autotrade/backend-go/internal/exchange"
+	"autotrade/backend-go/internal/model"
+	"autotrade/backend-go/internal/repo"
+	"github.com/jackc/pgx/v5"
+)
+
+// Reconcile compares every account with recent live orders against the
+// venue, repairs missing fills and order states, and stores a report per
+// account. Paper orders share the local tables with their executor, so
+// there is nothing to reconcile for them.
+func (s *Service) Reconcile(ctx context.Context) ([]model.ReconciliationReport, error) {
+	if s.live == nil {
+		return nil, nil
+	}
+	accounts, err := s.repo.ReconcileAccounts(ctx, model.ExecutionLive, s.cfg.ReconcileLookback.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]model.ReconciliationReport, 0, len(accounts))
+	for _, account := range accounts {
+		rep := model.ReconciliationReport{
+			Account:            account,
+			Execution:          model.ExecutionLive,
+			OrdersOrphaned:     []int64{},
+			FillsRepaired:      []string{},
+			UnknownVenueOrders: []string{},
+			PositionDrift:      []model.PositionDrift{},
+			StartedAt:          time.Now().UTC(),
+		}
+		if err := s.reconcileAccount(ctx, s.live, &rep); err != nil {
+			rep.Error = err.Error()
+		}
+		rep.FinishedAt = time.Now().UTC()
+		if rep.ID, err = s.repo.SaveReconciliationReport(ctx, rep); err != nil {
+			return reports, err
+		}
+		reports = append(reports, rep)
+	}
+	return reports, nil
+}
+
+func (s *Service) reconcileAccount(ctx context.Context, ex exchange.Executor, rep *model.ReconciliationReport) error {
+	account := rep.Account
+	fills, err := ex.Fills(ctx, account, rep.StartedAt.Add(-s.cfg.ReconcileLookback))
+	if err != nil {
+		return err
+	}
+	for _, f := range fills {
+		repaired, err := s.repairFill(ctx, account, rep.Execution, f)
+		if err != nil {
+			return err
+		}
+		if repaired {
+			rep.FillsRepaired = append(rep.FillsRepaired, f.TradeID)
+		}
+	}
+
+	open, err := ex.OpenOrders(ctx, account)
+	if err != nil {
+		return err
+	}
+	venueOpen := make(map[string]exchange.OrderState, len(open))
+	for _, st := range open {
+		venueOpen[st.VenueOrderID] = st
+	}
+	orders, err := s.repo.GetWorkingOrders(ctx, account, model.BookExecutions(rep.Execution))
+	if err != nil {
+		return err
+	}
+	tracked := make(map[string]bool, len(orders))
+	for i := range orders {
+		o := &orders[i]
+		rep.OrdersChecked++
+		updated, orphaned, err := s.reconcileOrder(ctx, ex, account, o, venueOpen, rep.StartedAt)
+		// reconcileOrder may have found the venue id of an unacknowledged order.
+		if o.VenueOrderID != "" {
+			tracked[o.VenueOrderID] = true
+		}
+		if IsConflict(err) {
+			// Moved by a user action or fill while we were looking.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if updated {
+			rep.OrdersUpdated++
+		}
+		if orphaned {
+			rep.OrdersOrphaned = append(rep.OrdersOrphaned, o.ID)
+		}
+	}
+	// Venue orders with no working local order: orders placed elsewhere, or
+	// orders we think are finished.
+	for id := range venueOpen {
+		if !tracked[id] {
+			rep.UnknownVenueOrders = append(rep.UnknownVenueOrders, id)
+		}
+	}
+	slices.Sort(rep.UnknownVenueOrders)
+
+	venuePositions, err := ex.Positions(ctx, account)
+	if err != nil {
+		return err
+	}
+	localPositions, err := s.repo.GetPositions(ctx, account, rep.Execution)
+	if err != nil {
+		return err
+	}
+	sizes := make(map[string]*model.PositionDrift)
+	drift := func(symbol string) *model.PositionDrift {
+		symbol = strings.ToUpper(symbol)
+		if sizes[symbol] == nil {
+			sizes[symbol] = &model.PositionDrift{Symbol: symbol}
+		}
+		return sizes[symbol]
+	}
+	for _, p := range localPositions {
+		drift(p.Symbol).LocalSize = signedSize(p)
+	}
+	for _, p := range venuePositions {
+		drift(p.Symbol).VenueSize = p.Size
+	}
+	for _, d := range sizes {
+		if math.Abs(d.LocalSize-d.VenueSize) > sizeEpsilon {
+			rep.PositionDrift = append(rep.PositionDrift, *d)
+		}
+	}
+	slices.SortFunc(rep.PositionDrift, func(a, b model.PositionDrift) int { return strings.Compare(a.Symbol, b.Symbol) })
+	return nil
+}
+
+// repairFill records a venue fill that is missing locally in execution's
+// positions and ledger, linking it to the order it belongs to when we know
+// it. Fills are deduplicated by trade id, and trades already covered by the
+// fill an order reported on placement are skipped.
+func (s *Service) repairFill(ctx context.Context, account, execution string, f exchange.Fill) (bool, error) {
+	if f.TradeID == "" {
+		return false, nil
+	}
+	seen, err := s.repo.HasVenueFill(ctx, account, f.TradeID)
+	if err != nil || seen {
+		return false, err
+	}
+	var orderID *int64
+	order, err := s.repo.GetOrderByVenueID(ctx, account, f.VenueOrderID)
+	switch {
+	case err == nil:
+		orderID = &order.ID
+		covered, err := s.repo.HasPlacementFill(ctx, account, order.ID, f.Time)
+		if err != nil || covered {
+			return false, err
+		}
+	case !errors.Is(err, pgx.ErrNoRows):
+		return false, err
+	}
+	err = s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+		_, err := s.recordFill(ctx, tx, account, execution, model.Fill{
+			OrderID:      orderID,
+			Symbol:       strings.ToUpper(f.Symbol),
+			Side:         f.Side,
+			Price:        f.Price,
+			Size:         f.Size,
+			Fee:          f.Fee,
+			Liquidity:    f.Liquidity,
+			VenueTradeID: f.TradeID,
+			CreatedAt:    f.Time,
+		})
+		return err
+	})
+	return err == nil, err
+}
+
+// reconcileOrder brings one working local order in line with the venue. An
+// unacknowledged order is looked up by its client id; one the venue never
+// received, or no longer knows, is orphaned. Bracket legs the venue holds
+// back until their entry fills are left alone while the entry is working.
+func (s *Service) reconcileOrder(ctx context.Context, ex exchange.Executor, account string, o *model.Order, venueOpen map[string]exchange.OrderState, now time.Time) (bool, bool, error) {
+	if o.VenueOrderID == "" {
+		if now.Sub(o.CreatedAt) < s.cfg.ReconcileOrphanAfter {
+			// Possibly still being submitted.
+			return false, false, nil
+		}
+		// The placement response may have been lost; the venue knows the
+		// order by the client id it was sent with.
+		st, err := ex.OrderStatusByClientID(ctx, account, o.ID)
+		if errors.Is(err, exchange.ErrNotFound) {
+			if held, err := s.heldLeg(ctx, account, o); held || err != nil {
+				return false, false, err
+			}
+			return false, true, s.orphan(ctx, account, o, "never acknowledged by venue")
+		}
+		if err != nil {
+			return false, false, err
+		}
+		err = s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+			if err := tx.SetVenueOrderID(ctx, account, o.ID, st.VenueOrderID); err != nil {
+				return err
+			}
+			_, err := s.syncOrder(ctx, tx, account, o, st)
+			return err
+		})
+		if err != nil {
+			return false, false, err
+		}
+		o.VenueOrderID = st.VenueOrderID
+		return true, false, nil
+	}
+	st, ok := venueOpen[o.VenueOrderID]
+	if !ok {
+		var err error
+		st, err = ex.OrderStatus(ctx, account, o.VenueOrderID)
+		if errors.Is(err, exchange.ErrNotFound) {
+			return false, true, s.orphan(ctx, account, o, "unknown to venue")
+		}
+		if err != nil {
+			return false, false, err
+		}
+	}
+	var updated bool
+	err := s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+		var err error
+		updated, err = s.syncOrder(ctx, tx, account, o, st)
+		return err
+	})
+	return updated, false, err
+}
+
+// heldLeg reports whether o is a bracket leg whose entry is still working.
+func (s *Service) heldLeg(ctx context.Context, account string, o *model.Order) (bool, error) {
+	if o.ParentID == nil {
+		return false, nil
+	}
+	parent, err := s.repo.GetOrder(ctx, account, *o.ParentID)
+	if err != nil {
+		return false, err
+	}
+	return !isTerminal(parent.Status), nil
+}
+
+func (s *Service) orphan(ctx context.Context, account string, o *model.Order, reason string) error {
+	return s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+		return s.orphanOrder(ctx, tx, account, o, reason)
+	})
+}
+
+func (s *Service) orphanOrder(ctx context.Context, r *repo.Repo, account string, o *model.Order, reason string) error {
+	to := model.OrderExpired
+	if o.Status == model.OrderPending {
+		to = model.OrderRejected
+	}
+	return s.advanceOrder(ctx, r, account, o, to, 0, map[string]any{"reason": "orphaned", "detail": reason})
+}
+
+// syncOrder moves o to the venue's status and filled size where the state
+// machine allows it. A venue rejection of an accepted order is treated as a
+// cancellation.
+func (s *Service) syncOrder(ctx context.Context, r *repo.Repo, account string, o *model.Order, st exchange.OrderState) (bool, error) {
+	detail := map[string]any{"reason": "reconciliation", "venueStatus": st.Status}
+	to := st.Status
+	updated := false
+	if o.Status == model.OrderPending && to != model.OrderRejected && to != model.OrderCancelled {
+		if err := s.advanceOrder(ctx, r, account, o, model.OrderOpen, 0, detail); err != nil {
+			return false, err
+		}
+		updated = true
+	}
+	if to == model.OrderRejected && o.Status != model.OrderPending {
+		to = model.OrderCancelled
+	}
+	filled := math.Max(o.FilledSize, st.FilledSize)
+	if to == o.Status && filled-o.FilledSize <= sizeEpsilon {
+		return updated, nil
+	}
+	if !canTransition(o.Status, to) {
+		return updated, nil
+	}
+	if err := s.advanceOrder(ctx, r, account, o, to, filled, detail); err != nil {
+		return updated, err
+	}
+	o.FilledSize = filled
+	return true, nil
+}
+
+func (s *Service) ReconciliationReports(ctx context.Context, account string, limit int) ([]model.ReconciliationReport, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.repo.GetReconciliationReports(ctx, account, limit)
+}
+
+// IsAdmin reports whether account is listed in ADMIN_ACCOUNTS.
+func (s *Service) IsAdmin(account string) bool {
+	return slices.ContainsFunc(s.cfg.AdminAccounts, func(a string) bool { return strings.EqualFold(a, account) })
+}
+
+func (s *Service) WatchReconciliation(ctx context.Context, every time.Duration) {
+	runEvery(ctx, every, "reconciliation", func(ctx context.Context) error {
+		_, err := s.Reconcile(ctx)
+		return err
+	})
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"autotrade/backend-go/internal/model"
+	"autotrade/backend-go/internal/repo"
+	"autotrade/backend-go/internal/risk"
+)
+
+type riskRejectedErr struct{ rejections []risk.Rejection }
+
+func (e riskRejectedErr) Error() string {
+	parts := make([]string, 0, len(e.rejections))
+	for _, rej := range e.rejections {
+		parts = append(parts, rej.Rule+": "+rej.Message)
+	}
+	return "rejected by risk checks: " + strings.Join(parts, "; ")
+}
+
+func RiskRejections(err error) ([]risk.Rejection, bool) {
+	e, ok := err.(riskRejectedErr)
+	return e.rejections, ok
+}
+
+func (s *Service) defaultRiskLimits() risk.Limits {
+	return risk.Limits{
+		MaxOrderNotional: s.cfg.RiskMaxOrderNotional,
+		MaxOpenPerSymbol: int(s.cfg.RiskMaxOpenPerSymbol),
+		MaxLeverage:      s.cfg.RiskMaxLeverage,
+		MaxLossAtStopPct: s.cfg.RiskMaxLossAtStopPct,
+		AllowedSymbols:   s.cfg.RiskAllowedSymbols,
+	}
+}
+
+// RiskLimits returns the account's limits, falling back to the configured defaults.
+func (s *Service) RiskLimits(ctx context.Context, account string) (risk.Limits, error) {
+	return s.riskLimits(ctx, s.repo, account)
+}
+
+func (s *Service) riskLimits(ctx context.Context, r *repo.Repo, account string) (risk.Limits, error) {
+	limits, found, err := r.GetRiskLimits(ctx, account)
+	if err != nil {
+		return risk.Limits{}, err
+	}
+	if !found {
+		return s.defaultRiskLimits(), nil
+	}
+	return limits, nil
+}
+
+func (s *Service) SetRiskLimits(ctx context.Context, account string, in risk.Limits) (risk.Limits, error) {
+	fields := FieldErrors{}
+	if in.MaxOrderNotional < 0 {
+		fields.add("maxOrderNotional", "must not be negative")
+	}
+	if in.MaxOpenPerSymbol < 0 {
+		fields.add("maxOpenPerSymbol", "must not be negative")
+	}
+	if in.MaxLeverage < 0 {
+		fields.add("maxLeverage", "must not be negative")
+	}
+	if in.MaxLossAtStopPct < 0 || in.MaxLossAtStopPct > 100 {
+		fields.add("maxLossAtStopPct", "must be between 0 and 100")
+	}
+	symbols := make([]string, 0, len(in.AllowedSymbols))
+	for _, sym := range in.AllowedSymbols {
+		if sym = strings.ToUpper(strings.TrimSpace(sym)); sym != "" {
+			symbols = append(symbols, sym)
+		}
+	}
+	in.AllowedSymbols = symbols
+	if err := fields.err(); err != nil {
+		return risk.Limits{}, err
+	}
+	if err := s.repo.SaveRiskLimits(ctx, account, in); err != nil {
+		return risk.Limits{}, err
+	}
+	return in, nil
+}
+
+// checkRisk evaluates the order against the account's limits and current
+// book in the order's execution, returning a riskRejectedErr listing every
+// failed rule. replacing, if non-zero, is a working order that in amends; it
+// is left out of the book.
+func (s *Service) checkRisk(ctx context.Context, r *repo.Repo, account string, in model.OrderInput, replacing int64) error {
+	limits, err := s.riskLimits(ctx, r, account)
+	if err != nil {
+		return err
+	}
+	book := bookOf(in.Execution)
+	exposure, err := r.GetExposure(ctx, account, book, replacing)
+	if err != nil {
+		return err
+	}
+	st, err := s.accountState(ctx, r, account, book)
+	if err != nil {
+		return err
+	}
+	exposure.Equity = st.Equity
+	rejections := s.risk.Evaluate(risk.Check{
+		Order: risk.Order{
+			Symbol:     in.Symbol,
+			Side:       in.Side,
+			Size:       in.Size,
+			EntryPrice: in.EntryPrice,
+			StopLoss:   in.StopLoss,
+		},
+		Limits:   limits,
+		Exposure: exposure,
+	})
+	if len(rejections) > 0 {
+		return riskRejectedErr{rejections: rejections}
+	}
+	return nil
+}
@@ -2,43 +2,109 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"log"
+	"slices"
 	"strings"
+	"time"
 
+	"autotrade/backend-go/internal/auth"
+	"autotrade/backend-go/internal/config"
+	"autotrade/backend-go/internal/exchange"
+	"autotrade/backend-go/internal/hyperliquid"
 	"autotrade/backend-go/internal/model"
+	"autotrade/backend-go/internal/paper"
+	"autotrade/backend-go/internal/realtime"
 	"autotrade/backend-go/internal/repo"
+	"autotrade/backend-go/internal/risk"
+	"autotrade/backend-go/internal/secret"
+	"autotrade/backend-go/internal/wallet"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/jackc/pgx/v5"
 )
 
 type Service struct {
-	repo *repo.Repo
+	repo      *repo.Repo
+	cfg       config.Config
+	tokens    *auth.Signer
+	agentKeys *secret.Box
+	risk      *risk.Engine
+	costs     paper.Costs
+	live      exchange.Executor
+	hub       *realtime.Hub
+	// candle is the length of a PAPER_MATCH_TIMEFRAME candle.
+	candle time.Duration
 }
 
-func New(r *repo.Repo) *Service {
-	return &Service{repo: r}
+func New(r *repo.Repo, cfg config.Config) (*Service, error) {
+	s := &Service{
+		repo:   r,
+		cfg:    cfg,
+		tokens: auth.NewSigner([]byte(cfg.SessionSecret), cfg.SessionTTL),
+		risk:   risk.NewEngine(risk.DefaultRules()...),
+	}
+	s.hub = realtime.NewHub(cfg.WSSendBuffer, cfg.WSReplayBuffer, eventStore{r})
+	slippage, err := paper.NewSlippage(cfg.PaperSlippageModel, cfg.PaperSlippageBps, cfg.PaperSlippageVolMult, cfg.PaperSlippageImpact)
+	if err != nil {
+		return nil, fmt.Errorf("PAPER_SLIPPAGE_MODEL: %w", err)
+	}
+	if s.candle, err = paper.ParseTimeframe(cfg.PaperMatchTimeframe); err != nil {
+		return nil, fmt.Errorf("PAPER_MATCH_TIMEFRAME: %w", err)
+	}
+	s.costs = paper.Costs{
+		Fees:        make(map[string]paper.Fees, len(cfg.PaperFees)),
+		DefaultFees: paper.Fees{MakerBps: cfg.PaperMakerBps, TakerBps: cfg.PaperTakerBps},
+		Slippage:    slippage,
+	}
+	for symbol, f := range cfg.PaperFees {
+		s.costs.Fees[symbol] = paper.Fees{MakerBps: f.MakerBps, TakerBps: f.TakerBps}
+	}
+	if cfg.AgentKeyKey != "" {
+		key, err := secret.ParseKey(cfg.AgentKeyKey)
+		if err != nil {
+			return nil, fmt.Errorf("AGENT_KEY_ENCRYPTION_KEY: %w", err)
+		}
+		if s.agentKeys, err = secret.NewBox(key); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.LiveTrading {
+		s.live = hyperliquid.New(hyperliquid.Config{
+			BaseURL:        cfg.HyperliquidAPIBase,
+			Mainnet:        cfg.HyperliquidMainnet,
+			MarketSlippage: cfg.HyperliquidMarketSlippage,
+		})
+	}
+	return s, nil
 }
 
-func (s *Service) State(ctx context.Context) (map[string]any, error) {
-	st, err := s.repo.GetState(ctx)
+func (s *Service) State(ctx context.Context, account, execution string) (map[string]any, error) {
+	execution, err := parseExecution(execution)
+	if err != nil {
+		return nil, err
+	}
+	st, err := s.accountState(ctx, s.repo, account, execution)
 	if err != nil {
 		return nil, err
 	}
-	bias, err := s.repo.GetBias(ctx)
+	bias, err := s.repo.GetBias(ctx, account)
 	if err != nil {
 		return nil, err
 	}
 	return map[string]any{"state": st, "bias": bias}, nil
 }
 
-func (s *Service) Fills(ctx context.Context, limit int) ([]model.Fill, error) {
+func (s *Service) Fills(ctx context.Context, account string, limit int) ([]model.Fill, error) {
 	if limit <= 0 || limit > 100 {
 		limit = 20
 	}
-	return s.repo.GetFills(ctx, limit)
+	return s.repo.GetFills(ctx, account, limit)
 }
 
-func (s *Service) Review(ctx context.Context, in model.ReviewInput) error {
+func (s *Service) Review(ctx context.Context, account string, in model.ReviewInput) error {
 	if in.FillID <= 0 {
 		return ErrBadRequest("fillId is required")
 	}
@@ -46,14 +112,21 @@ func (s *Service) Review(ctx context.Context, in model.ReviewInput) error {
 	if in.Verdict != "good" && in.Verdict != "bad" {
 		return ErrBadRequest("verdict must be good or bad")
 	}
-	return s.repo.SaveReview(ctx, in)
+	saved, err := s.repo.SaveReview(ctx, account, in)
+	if err != nil {
+		return err
+	}
+	if !saved {
+		return ErrBadRequest("fill not found")
+	}
+	return nil
 }
 
 func (s *Service) Derives(ctx context.Context) ([]model.StrategyDerive, error) {
 	return s.repo.GetDerives(ctx)
 }
 
-func (s *Service) SetBias(ctx context.Context, bias string) error {
+func (s *Service) SetBias(ctx context.Context, account, bias string) error {
 	normalized := strings.ToLower(strings.TrimSpace(bias))
 	var out string
 	switch normalized {
@@ -66,11 +139,11 @@ func (s *Service) SetBias(ctx context.Context, bias string) error {
 	default:
 		return ErrBadRequest("bias must be Long, Short, or Hybrid")
 	}
-	return s.repo.SetBias(ctx, out)
+	return s.repo.SetBias(ctx, account, out)
 }
 
-func (s *Service) WalletSession(ctx context.Context) (model.WalletSession, error) {
-	out, err := s.repo.GetLatestWalletSession(ctx)
+func (s *Service) WalletSession(ctx context.Context, account string) (model.WalletSession, error) {
+	out, err := s.repo.GetLatestWalletSession(ctx, account)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return model.WalletSession{}, nil
@@ -80,80 +153,238 @@ func (s *Service) WalletSession(ctx context.Context) (model.WalletSession, error
 	return out, nil
 }
 
-func (s *Service) ConnectWallet(ctx context.Context, address, signature, message string) error {
-	if !strings.HasPrefix(strings.ToLower(address), "0x") || len(address) < 10 {
-		return ErrBadRequest("invalid wallet address")
+func (s *Service) WalletNonce(ctx context.Context) (model.WalletNonce, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return model.WalletNonce{}, err
 	}
-	if strings.TrimSpace(signature) == "" {
-		return ErrBadRequest("signature is required")
+	now := time.Now().UTC()
+	out := model.WalletNonce{
+		Nonce:     hex.EncodeToString(buf),
+		ChainID:   s.cfg.SIWEChainID,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(s.cfg.SIWENonceTTL),
+	}
+	if len(s.cfg.SIWEDomains) > 0 {
+		out.Domain = s.cfg.SIWEDomains[0]
+	}
+	if err := s.repo.CreateWalletNonce(ctx, out.Nonce, out.ExpiresAt); err != nil {
+		return model.WalletNonce{}, err
 	}
-	return s.repo.SaveWalletSession(ctx, address, signature, message)
+	return out, nil
 }
 
-func (s *Service) ApproveAgent(ctx context.Context) (string, error) {
-	ws, err := s.repo.GetLatestWalletSession(ctx)
+func (s *Service) ConnectWallet(ctx context.Context, address, signature, message string) (model.SessionToken, error) {
+	address, err := wallet.NormalizeAddress(address)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return "", ErrBadRequest("connect wallet first")
+		return model.SessionToken{}, ErrBadRequest(err.Error())
+	}
+	if strings.TrimSpace(signature) == "" {
+		return model.SessionToken{}, ErrBadRequest("signature is required")
+	}
+	msg, err := wallet.ParseSIWE(message)
+	if err != nil {
+		return model.SessionToken{}, ErrBadRequest(err.Error())
+	}
+	now := time.Now().UTC()
+	if err := s.checkSIWE(msg, address, now); err != nil {
+		return model.SessionToken{}, err
+	}
+	signer, err := wallet.RecoverPersonalSign(message, signature)
+	if err != nil {
+		return model.SessionToken{}, ErrBadRequest(err.Error())
+	}
+	if signer != address {
+		return model.SessionToken{}, errUnauthorizedCode(AuthSignerMismatch, "signature does not match wallet address")
+	}
+	// The nonce is only spent if the account and session are saved too.
+	err = s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+		ok, err := tx.ConsumeWalletNonce(ctx, msg.Nonce)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errUnauthorizedCode(AuthNonceInvalid, "nonce is unknown, expired or already used")
+		}
+		if err := tx.EnsureAccount(ctx, address, s.cfg.PaperStartingBalance); err != nil {
+			return err
 		}
-		return "", err
+		return tx.SaveWalletSession(ctx, address, signature, message)
+	})
+	if err != nil {
+		return model.SessionToken{}, err
 	}
-	agent := fmt.Sprintf("agent_%s", strings.ToLower(ws.Address[len(ws.Address)-8:]))
-	if err := s.repo.ApproveAgent(ctx, agent); err != nil {
-		return "", err
+	token, claims, err := s.tokens.Issue(address, now)
+	if err != nil {
+		return model.SessionToken{}, err
 	}
-	return agent, nil
+	return model.SessionToken{Token: token, Address: address, ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC()}, nil
 }
 
-func (s *Service) StrategyStatus(ctx context.Context) (model.StrategyStatus, error) {
-	return s.repo.StrategyStatus(ctx)
+func (s *Service) Authenticate(token string) (string, error) {
+	claims, err := s.tokens.Verify(token, time.Now().UTC())
+	if err != nil {
+		return "", ErrUnauthorized(err.Error())
+	}
+	return claims.Subject, nil
 }
 
-func (s *Service) SetAutoTrading(ctx context.Context, enabled bool) error {
-	return s.repo.SetAutoTrading(ctx, enabled)
+func (s *Service) checkSIWE(msg wallet.SIWEMessage, address string, now time.Time) error {
+	if !slices.Contains(s.cfg.SIWEDomains, msg.Domain) {
+		return errUnauthorizedCode(AuthMessageRejected, "sign-in domain is not allowed")
+	}
+	if msg.ChainID != s.cfg.SIWEChainID {
+		return errUnauthorizedCode(AuthMessageRejected, "sign-in chain id mismatch")
+	}
+	msgAddress, err := wallet.NormalizeAddress(msg.Address)
+	if err != nil || msgAddress != address {
+		return errUnauthorizedCode(AuthMessageRejected, "sign-in address does not match wallet address")
+	}
+	skew := s.cfg.SIWEClockSkew
+	if msg.IssuedAt.After(now.Add(skew)) {
+		return errUnauthorizedCode(AuthMessageRejected, "sign-in message issued in the future")
+	}
+	if msg.IssuedAt.Before(now.Add(-s.cfg.SIWENonceTTL - skew)) {
+		return errUnauthorizedCode(AuthMessageExpired, "sign-in message is too old")
+	}
+	if msg.ExpirationTime != nil && !msg.ExpirationTime.After(now.Add(-skew)) {
+		return errUnauthorizedCode(AuthMessageExpired, "sign-in message has expired")
+	}
+	if msg.NotBefore != nil && msg.NotBefore.After(now.Add(skew)) {
+		return errUnauthorizedCode(AuthMessageRejected, "sign-in message is not yet valid")
+	}
+	return nil
 }
 
-func (s *Service) CreateOrder(ctx context.Context, in model.OrderInput) (int64, error) {
-	if strings.TrimSpace(in.Symbol) == "" || strings.TrimSpace(in.Side) == "" {
-		return 0, ErrBadRequest("symbol and side are required")
+// ApproveAgent generates a new agent key for the account, superseding any
+// active one. The returned address is what the user approves on Hyperliquid.
+func (s *Service) ApproveAgent(ctx context.Context, account string) (model.AgentApproval, error) {
+	if s.agentKeys == nil {
+		return model.AgentApproval{}, errors.New("agent key encryption is not configured")
 	}
-	if in.Size <= 0 || in.EntryPrice <= 0 {
-		return 0, ErrBadRequest("size and entryPrice must be positive")
+	if _, err := s.repo.GetLatestWalletSession(ctx, account); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.AgentApproval{}, ErrBadRequest("connect wallet first")
+		}
+		return model.AgentApproval{}, err
 	}
-	if in.StopLoss <= 0 || in.TakeProfit <= 0 {
-		return 0, ErrBadRequest("stopLoss and takeProfit are required")
+	key, agentAddress, err := wallet.GenerateKey()
+	if err != nil {
+		return model.AgentApproval{}, err
 	}
-	if strings.TrimSpace(in.Execution) == "" {
-		in.Execution = "paper"
+	defer key.Zero()
+	sealed, err := s.agentKeys.Seal(key.Serialize(), agentKeyAD(account, agentAddress))
+	if err != nil {
+		return model.AgentApproval{}, err
 	}
-	approved, err := s.repo.IsAgentApproved(ctx)
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		return 0, err
+	validUntil := time.Now().UTC().Add(s.cfg.AgentTTL)
+	err = s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+		return tx.RotateAgentKey(ctx, account, agentAddress, sealed, validUntil)
+	})
+	if err != nil {
+		return model.AgentApproval{}, err
 	}
-	if !approved {
-		return 0, ErrBadRequest("agent is not approved")
+	return model.AgentApproval{AgentAddress: agentAddress, ValidUntil: validUntil}, nil
+}
+
+func (s *Service) RevokeAgent(ctx context.Context, account, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		reason = "revoked by user"
 	}
-	if strings.TrimSpace(in.OrderType) == "" {
-		in.OrderType = "market"
+	return s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+		revoked, err := tx.RevokeAgentKey(ctx, account, reason)
+		if err != nil {
+			return err
+		}
+		if !revoked {
+			return ErrBadRequest("no active agent")
+		}
+		return tx.BlockAutoTrading(ctx, account, "agent revoked: "+reason)
+	})
+}
+
+// ExpireAgents retires lapsed agent approvals and blocks auto trading for
+// the affected accounts. Both happen in one transaction, so a failed block
+// leaves the keys active for the next sweep.
+func (s *Service) ExpireAgents(ctx context.Context) error {
+	return s.repo.WithTx(ctx, func(tx *repo.Repo) error {
+		accounts, err := tx.ExpireAgentKeys(ctx)
+		if err != nil {
+			return err
+		}
+		for _, account := range accounts {
+			if err := tx.BlockAutoTrading(ctx, account, "agent approval expired"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Service) WatchAgentApprovals(ctx context.Context, every time.Duration) {
+	runEvery(ctx, every, "agent expiry sweep", func(ctx context.Context) error {
+		return s.ExpireAgents(ctx)
+	})
+}
+
+// runEvery calls fn now and then every d until ctx is done, logging failures
+// under name.
+func runEvery(ctx context.Context, d time.Duration, name string, fn func(context.Context) error) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		if err := fn(ctx); err != nil {
+			log.Printf("%s failed: %v", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// agentKeyAD binds a sealed agent key to its owner so rows cannot be swapped.
+func agentKeyAD(account, agentAddress string) []byte {
+	return []byte(account + "|" + agentAddress)
+}
+
+// agentSigner unseals the account's active agent key for signing venue
+// actions. Callers must Zero it when done.
+func (s *Service) agentSigner(ctx context.Context, account string) (*secp256k1.PrivateKey, error) {
+	if s.agentKeys == nil {
+		return nil, errors.New("agent key encryption is not configured")
+	}
+	agentAddress, sealed, err := s.repo.GetActiveAgentKey(ctx, account)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrBadRequest("approve an agent first")
 	}
-	id, err := s.repo.CreateOrder(ctx, in)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	_ = s.repo.CreateFillFromOrder(ctx, in)
-	prettySide := strings.ToLower(in.Side)
-	if len(prettySide) > 0 {
-		prettySide = strings.ToUpper(prettySide[:1]) + prettySide[1:]
+	raw, err := s.agentKeys.Open(sealed, agentKeyAD(account, agentAddress))
+	if err != nil {
+		return nil, fmt.Errorf("unseal agent key: %w", err)
 	}
-	s.repo.TouchSignal(ctx, fmt.Sprintf("%s %s %.4f", strings.ToUpper(in.Symbol), prettySide, in.Size))
-	return id, nil
+	return secp256k1.PrivKeyFromBytes(raw), nil
 }
 
-func (s *Service) Orders(ctx context.Context, limit int) ([]model.Order, error) {
-	if limit <= 0 || limit > 100 {
-		limit = 20
+func (s *Service) StrategyStatus(ctx context.Context, account string) (model.StrategyStatus, error) {
+	return s.repo.StrategyStatus(ctx, account)
+}
+
+func (s *Service) SetAutoTrading(ctx context.Context, account string, enabled bool) error {
+	if enabled {
+		approved, err := s.repo.IsAgentApproved(ctx, account)
+		if err != nil {
+			return err
+		}
+		if !approved {
+			return ErrBadRequest("agent is not approved")
+		}
 	}
-	return s.repo.GetOrders(ctx, limit)
+	return s.repo.SetAutoTrading(ctx, account, enabled)
 }
 
 type badRequestErr struct{ msg string }
@@ -166,3 +397,54 @@ func IsBadRequest(err error) bool {
 	_, ok := err.(badRequestErr)
 	return ok
 }
+
+// Codes on unauthorized wallet-connect errors, so a client can tell a wrong
+// signer from a nonce it has to fetch again.
+const (
+	AuthSignerMismatch  = "signer_mismatch"
+	AuthNonceInvalid    = "nonce_invalid"
+	AuthMessageRejected = "message_rejected"
+	AuthMessageExpired  = "message_expired"
+)
+
+type unauthorizedErr struct{ msg, code string }
+
+func (e unauthorizedErr) Error() string { return e.msg }
+
+func ErrUnauthorized(msg string) error { return unauthorizedErr{msg: msg} }
+
+func errUnauthorizedCode(code, msg string) error { return unauthorizedErr{msg: msg, code: code} }
+
+func IsUnauthorized(err error) bool {
+	_, ok := err.(unauthorizedErr)
+	return ok
+}
+
+// UnauthorizedCode returns the machine-readable code of an unauthorized
+// error, if it carries one.
+func UnauthorizedCode(err error) (string, bool) {
+	e, ok := err.(unauthorizedErr)
+	return e.code, ok && e.code != ""
+}
+
+type notFoundErr struct{ msg string }
+
+func (e notFoundErr) Error() string { return e.msg }
+
+func ErrNotFound(msg string) error { return notFoundErr{msg: msg} }
+
+func IsNotFound(err error) bool {
+	_, ok := err.(notFoundErr)
+	return ok
+}
+
+type conflictErr struct{ msg string }
+
+func (e conflictErr) Error() string { return e.msg }
+
+func ErrConflict(msg string) error { return conflictErr{msg: msg} }
+
+func IsConflict(err error) bool {
+	_, ok := err.(conflictErr)
+	return ok
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"autotrade/backend-go/internal/config"
+	"autotrade/backend-go/internal/wallet"
+)
+
+func TestCheckSIWE(t *testing.T) {
+	s := &Service{cfg: config.Config{
+		SIWEDomains:   []string{"app.example.com"},
+		SIWEChainID:   1,
+		SIWENonceTTL:  5 * time.Minute,
+		SIWEClockSkew: time.Minute,
+	}}
+	address := "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"
+	now := time.Date(2026, 1, 2, 3, 5, 0, 0, time.UTC)
+	at := func(t time.Time) *time.Time { return &t }
+	valid := func() wallet.SIWEMessage {
+		return wallet.SIWEMessage{
+			Domain:   "app.example.com",
+			Address:  address,
+			URI:      "https://app.example.com",
+			Version:  "1",
+			ChainID:  1,
+			Nonce:    "32891756abcdef",
+			IssuedAt: now.Add(-time.Minute),
+		}
+	}
+	tests := []struct {
+		name   string
+		modify func(*wallet.SIWEMessage)
+		code   string // empty when the message is accepted
+	}{
+		{"valid", func(*wallet.SIWEMessage) {}, ""},
+		{"lowercase address", func(m *wallet.SIWEMessage) { m.Address = "0x2c7536e3605d9c16a7a3d7b1898e529396a65c23" }, ""},
+		{"issued within skew", func(m *wallet.SIWEMessage) { m.IssuedAt = now.Add(30 * time.Second) }, ""},
+		{"unknown domain", func(m *wallet.SIWEMessage) { m.Domain = "evil.example.com" }, AuthMessageRejected},
+		{"wrong chain", func(m *wallet.SIWEMessage) { m.ChainID = 5 }, AuthMessageRejected},
+		{"wrong address", func(m *wallet.SIWEMessage) { m.Address = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed" }, AuthMessageRejected},
+		{"bad checksum", func(m *wallet.SIWEMessage) { m.Address = "0x2c7536e3605D9C16a7a3D7b1898e529396a65c23" }, AuthMessageRejected},
+		{"issued in future", func(m *wallet.SIWEMessage) { m.IssuedAt = now.Add(2 * time.Minute) }, AuthMessageRejected},
+		{"too old", func(m *wallet.SIWEMessage) { m.IssuedAt = now.Add(-7 * time.Minute) }, AuthMessageExpired},
+		{"expired", func(m *wallet.SIWEMessage) { m.ExpirationTime = at(now.Add(-2 * time.Minute)) }, AuthMessageExpired},
+		{"not yet valid", func(m *wallet.SIWEMessage) { m.NotBefore = at(now.Add(2 * time.Minute)) }, AuthMessageRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := valid()
+			tt.modify(&msg)
+			err := s.checkSIWE(msg, address, now)
+			if tt.code == "" && err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if tt.code == "" {
+				return
+			}
+			if code, ok := UnauthorizedCode(err); !IsUnauthorized(err) || !ok || code != tt.code {
+				t.Fatalf("err = %v (code %q), want unauthorized %s", err, code, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"autotrade/backend-go/internal/model"
+	"autotrade/backend-go/internal/repo"
+	"autotrade/backend-go/internal/risk"
+)
+
+func (s *Service) lotSize(symbol string) float64 {
+	if lot, ok := s.cfg.LotSizes[symbol]; ok && lot > 0 {
+		return lot
+	}
+	return s.cfg.DefaultLotSize
+}
+
+// SizePosition works out how much to trade so that hitting the stop loses
+// riskPct of the execution's equity (or the fixed riskAmount), rounded down
+// to the lot size.
+func (s *Service) SizePosition(ctx context.Context, account string, in model.SizeInput) (model.SizeQuote, error) {
+	fields := FieldErrors{}
+	in.Symbol = strings.ToUpper(strings.TrimSpace(in.Symbol))
+	if in.Symbol == "" {
+		fields.add("symbol", "is required")
+	}
+	side, ok := normalizeSide(in.Side)
+	if !ok {
+		fields.add("side", "must be Buy or Sell (long/short accepted)")
+	}
+	in.Side = side
+	execution, err := parseExecution(in.Execution)
+	if err != nil {
+		fields.add("execution", "must be paper or live")
+	}
+	in.Execution = execution
+	if in.EntryPrice <= 0 {
+		fields.add("entryPrice", "must be positive")
+	}
+	if in.StopLoss <= 0 {
+		fields.add("stopLoss", "is required")
+	}
+	validateRiskSpec(in.RiskPct, in.RiskAmount, fields)
+	if in.EntryPrice > 0 && in.StopLoss > 0 {
+		if side == model.SideBuy && in.StopLoss >= in.EntryPrice {
+			fields.add("stopLoss", "must be below entryPrice for a Buy")
+		}
+		if side == model.SideSell && in.StopLoss <= in.EntryPrice {
+			fields.add("stopLoss", "must be above entryPrice for a Sell")
+		}
+	}
+	if err := fields.err(); err != nil {
+		return model.SizeQuote{}, err
+	}
+	return s.sizePosition(ctx, s.repo, account, in)
+}
+
+func validateRiskSpec(riskPct, riskAmount float64, fields FieldErrors) {
+	switch {
+	case riskPct != 0 && riskAmount != 0:
+		fields.add("riskPct", "give either riskPct or riskAmount, not both")
+	case riskPct == 0 && riskAmount == 0:
+		fields.add("riskPct", "riskPct or riskAmount is required")
+	case riskPct < 0 || riskPct > 100:
+		fields.add("riskPct", "must be between 0 and 100")
+	case riskAmount < 0:
+		fields.add("riskAmount", "must be positive")
+	}
+}
+
+// sizePosition expects an already validated input.
+func (s *Service) sizePosition(ctx context.Context, r *repo.Repo, account string, in model.SizeInput) (model.SizeQuote, error) {
+	st, err := s.accountState(ctx, r, account, bookOf(in.Execution))
+	if err != nil {
+		return model.SizeQuote{}, err
+	}
+	riskAmount := in.RiskAmount
+	if in.RiskPct > 0 {
+		riskAmount = st.Equity * in.RiskPct / 100
+	}
+	lot := s.lotSize(in.Symbol)
+	size := risk.SizeForRisk(riskAmount, in.EntryPrice, in.StopLoss, lot)
+	if size <= 0 {
+		return model.SizeQuote{}, ErrBadRequest("risk budget is smaller than one lot at this stop distance")
+	}
+	o := risk.Order{Symbol: in.Symbol, Side: in.Side, Size: size, EntryPrice: in.EntryPrice, StopLoss: in.StopLoss}
+	out := model.SizeQuote{
+		Symbol:     in.Symbol,
+		Side:       in.Side,
+		Size:       size,
+		LotSize:    lot,
+		RiskAmount: riskAmount,
+		Notional:   o.Notional(),
+		LossAtStop: o.LossAtStop(),
+		Equity:     st.Equity,
+	}
+	if st.Equity > 0 {
+		out.Leverage = out.Notional / st.Equity
+	}
+	return out, nil
+}
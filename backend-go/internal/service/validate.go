@@ -0,0 +1,110 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"autotrade/backend-go/internal/model"
+)
+
+// FieldErrors maps request field names (as the client sends them) to messages.
+type FieldErrors map[string]string
+
+func (f FieldErrors) add(field, msg string) {
+	if _, ok := f[field]; !ok {
+		f[field] = msg
+	}
+}
+
+func (f FieldErrors) err() error {
+	if len(f) == 0 {
+		return nil
+	}
+	return validationErr{fields: f}
+}
+
+type validationErr struct{ fields FieldErrors }
+
+func (e validationErr) Error() string {
+	names := make([]string, 0, len(e.fields))
+	for name := range e.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+": "+e.fields[name])
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+func ValidationFields(err error) (FieldErrors, bool) {
+	v, ok := err.(validationErr)
+	return v.fields, ok
+}
+
+var sideAliases = map[string]string{
+	"buy":   model.SideBuy,
+	"b":     model.SideBuy,
+	"long":  model.SideBuy,
+	"bid":   model.SideBuy,
+	"sell":  model.SideSell,
+	"s":     model.SideSell,
+	"short": model.SideSell,
+	"ask":   model.SideSell,
+}
+
+func normalizeSide(side string) (string, bool) {
+	out, ok := sideAliases[strings.ToLower(strings.TrimSpace(side))]
+	return out, ok
+}
+
+// validateBracket checks that stop-loss and take-profit sit on the correct
+// side of entry for the order direction, far enough away, and with an
+// acceptable reward/risk ratio.
+func (s *Service) validateBracket(side string, entry, stopLoss, takeProfit float64, fields FieldErrors) {
+	if entry <= 0 || stopLoss <= 0 || takeProfit <= 0 {
+		return
+	}
+	misplaced := false
+	switch side {
+	case model.SideBuy:
+		if stopLoss >= entry {
+			fields.add("stopLoss", "must be below entryPrice for a Buy")
+			misplaced = true
+		}
+		if takeProfit <= entry {
+			fields.add("takeProfit", "must be above entryPrice for a Buy")
+			misplaced = true
+		}
+	case model.SideSell:
+		if stopLoss <= entry {
+			fields.add("stopLoss", "must be above entryPrice for a Sell")
+			misplaced = true
+		}
+		if takeProfit >= entry {
+			fields.add("takeProfit", "must be below entryPrice for a Sell")
+			misplaced = true
+		}
+	default:
+		return
+	}
+	if misplaced {
+		return
+	}
+
+	risk := math.Abs(entry - stopLoss)
+	reward := math.Abs(takeProfit - entry)
+	minDistance := entry * s.cfg.MinStopDistanceBps / 10000
+	if risk < minDistance {
+		fields.add("stopLoss", fmt.Sprintf("must be at least %.2f bps from entryPrice", s.cfg.MinStopDistanceBps))
+	}
+	if reward < minDistance {
+		fields.add("takeProfit", fmt.Sprintf("must be at least %.2f bps from entryPrice", s.cfg.MinStopDistanceBps))
+	}
+	if risk > 0 && reward/risk < s.cfg.MinRewardRisk {
+		fields.add("takeProfit", fmt.Sprintf("reward/risk %.2f is below the minimum %.2f", reward/risk, s.cfg.MinRewardRisk))
+	}
+}
@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const siweHeaderSuffix = " wants you to sign in with your Ethereum account:"
+
+var ErrInvalidSIWE = errors.New("invalid sign-in message")
+
+// SIWEMessage is a parsed EIP-4361 message.
+type SIWEMessage struct {
+	Domain         string
+	Address        string
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+func ParseSIWE(message string) (SIWEMessage, error) {
+	var out SIWEMessage
+	lines := strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n")
+	if len(lines) < 4 || !strings.HasSuffix(lines[0], siweHeaderSuffix) || lines[2] != "" {
+		return out, ErrInvalidSIWE
+	}
+	out.Domain = strings.TrimSuffix(lines[0], siweHeaderSuffix)
+	out.Address = lines[1]
+
+	i := 3
+	if !strings.HasPrefix(lines[i], "URI: ") {
+		out.Statement = lines[i]
+		i++
+		if i >= len(lines) || lines[i] != "" {
+			return out, ErrInvalidSIWE
+		}
+		i++
+	}
+
+	var issuedAt string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			if line == "Resources:" {
+				for i++; i < len(lines) && strings.HasPrefix(lines[i], "- "); i++ {
+					out.Resources = append(out.Resources, strings.TrimPrefix(lines[i], "- "))
+				}
+				i--
+				continue
+			}
+			if line == "" && i == len(lines)-1 {
+				continue
+			}
+			return out, ErrInvalidSIWE
+		}
+		switch key {
+		case "URI":
+			out.URI = value
+		case "Version":
+			out.Version = value
+		case "Chain ID":
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return out, ErrInvalidSIWE
+			}
+			out.ChainID = id
+		case "Nonce":
+			out.Nonce = value
+		case "Issued At":
+			issuedAt = value
+		case "Expiration Time":
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return out, ErrInvalidSIWE
+			}
+			out.ExpirationTime = &t
+		case "Not Before":
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return out, ErrInvalidSIWE
+			}
+			out.NotBefore = &t
+		case "Request ID":
+			out.RequestID = value
+		default:
+			return out, ErrInvalidSIWE
+		}
+	}
+
+	if out.Domain == "" || out.URI == "" || out.Version != "1" || out.ChainID == 0 || len(out.Nonce) < 8 || issuedAt == "" {
+		return out, ErrInvalidSIWE
+	}
+	t, err := time.Parse(time.RFC3339, issuedAt)
+	if err != nil {
+		return out, ErrInvalidSIWE
+	}
+	out.IssuedAt = t
+	return out, nil
+}
@@ -0,0 +1,87 @@
+package wallet
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const siweMessage = "app.example.com wants you to sign in with your Ethereum account:\n" +
+	"0x2c7536E3605D9C16a7a3D7b1898e529396a65c23\n" +
+	"\n" +
+	"Sign in to autotrade.\n" +
+	"\n" +
+	"URI: https://app.example.com\n" +
+	"Version: 1\n" +
+	"Chain ID: 1\n" +
+	"Nonce: 32891756abcdef\n" +
+	"Issued At: 2026-01-02T03:04:05Z\n" +
+	"Expiration Time: 2026-01-02T03:14:05Z\n" +
+	"Not Before: 2026-01-02T03:00:00Z\n" +
+	"Request ID: req-1\n" +
+	"Resources:\n" +
+	"- https://app.example.com/terms\n" +
+	"- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq"
+
+func TestParseSIWE(t *testing.T) {
+	msg, err := ParseSIWE(siweMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	switch {
+	case msg.Domain != "app.example.com",
+		msg.Address != vectorAddress,
+		msg.Statement != "Sign in to autotrade.",
+		msg.URI != "https://app.example.com",
+		msg.Version != "1",
+		msg.ChainID != 1,
+		msg.Nonce != "32891756abcdef",
+		!msg.IssuedAt.Equal(issued),
+		msg.ExpirationTime == nil || !msg.ExpirationTime.Equal(issued.Add(10*time.Minute)),
+		msg.NotBefore == nil || !msg.NotBefore.Equal(time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)),
+		msg.RequestID != "req-1",
+		len(msg.Resources) != 2:
+		t.Fatalf("parsed %+v", msg)
+	}
+}
+
+func TestParseSIWEVariants(t *testing.T) {
+	minimal := "app.example.com wants you to sign in with your Ethereum account:\n" +
+		vectorAddress + "\n\n" +
+		"URI: https://app.example.com\nVersion: 1\nChain ID: 1\nNonce: 32891756abcdef\nIssued At: 2026-01-02T03:04:05Z"
+	tests := []struct {
+		name    string
+		message string
+		ok      bool
+	}{
+		{"full", siweMessage, true},
+		{"no statement", minimal, true},
+		{"crlf", strings.ReplaceAll(siweMessage, "\n", "\r\n"), true},
+		{"trailing newline", minimal + "\n", true},
+		{"bad header", strings.Replace(siweMessage, "wants you to sign in", "wants you to log in", 1), false},
+		{"missing blank line", strings.Replace(minimal, "\n\nURI", "\nURI", 1), false},
+		{"statement without blank line", strings.Replace(siweMessage, "autotrade.\n\n", "autotrade.\n", 1), false},
+		{"wrong version", strings.Replace(minimal, "Version: 1", "Version: 2", 1), false},
+		{"bad chain id", strings.Replace(minimal, "Chain ID: 1", "Chain ID: one", 1), false},
+		{"short nonce", strings.Replace(minimal, "32891756abcdef", "1234567", 1), false},
+		{"missing nonce", strings.Replace(minimal, "Nonce: 32891756abcdef\n", "", 1), false},
+		{"missing uri", strings.Replace(minimal, "URI: https://app.example.com\n", "", 1), false},
+		{"bad issued at", strings.Replace(minimal, "2026-01-02T03:04:05Z", "yesterday", 1), false},
+		{"bad expiration", strings.Replace(siweMessage, "2026-01-02T03:14:05Z", "soon", 1), false},
+		{"unknown field", minimal + "\nColor: blue", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSIWE(tt.message)
+			if tt.ok && err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidSIWE) {
+				t.Fatalf("err = %v, want ErrInvalidSIWE", err)
+			}
+		})
+	}
+}
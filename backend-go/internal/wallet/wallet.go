@@ -0,0 +1,108 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+var (
+	ErrInvalidAddress   = errors.New("invalid wallet address")
+	ErrInvalidChecksum  = errors.New("wallet address checksum mismatch")
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+func Keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+// PersonalMessageHash is the EIP-191 (version 0x45) digest signed by personal_sign.
+func PersonalMessageHash(message string) []byte {
+	prefix := fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(message))
+	return Keccak256([]byte(prefix), []byte(message))
+}
+
+// NormalizeAddress validates a hex address and returns its EIP-55 form.
+// All-lowercase and all-uppercase inputs are accepted as-is; mixed case
+// must carry a valid checksum.
+func NormalizeAddress(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if len(address) != 42 || (!strings.HasPrefix(address, "0x") && !strings.HasPrefix(address, "0X")) {
+		return "", ErrInvalidAddress
+	}
+	body := address[2:]
+	raw, err := hex.DecodeString(body)
+	if err != nil {
+		return "", ErrInvalidAddress
+	}
+	out := ChecksumAddress(raw)
+	if body != strings.ToLower(body) && body != strings.ToUpper(body) && out[2:] != body {
+		return "", ErrInvalidChecksum
+	}
+	return out, nil
+}
+
+func ChecksumAddress(raw []byte) string {
+	lower := hex.EncodeToString(raw)
+	hash := hex.EncodeToString(Keccak256([]byte(lower)))
+	out := []byte(lower)
+	for i, c := range out {
+		if c >= 'a' && c <= 'f' && hash[i] >= '8' {
+			out[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(out)
+}
+
+func PubKeyAddress(pub *secp256k1.PublicKey) string {
+	return ChecksumAddress(Keccak256(pub.SerializeUncompressed()[1:])[12:])
+}
+
+// RecoverPersonalSign returns the checksummed address that produced a
+// 65-byte r||s||v personal_sign signature over message. High-s signatures
+// are rejected.
+func RecoverPersonalSign(message, signature string) (string, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(signature), "0x"))
+	if err != nil || len(sig) != 65 {
+		return "", ErrInvalidSignature
+	}
+	v := sig[64]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return "", ErrInvalidSignature
+	}
+	// Only the low-s form is accepted (EIP-2), so a signature cannot be
+	// reshaped into a second valid one for the same message.
+	var sv secp256k1.ModNScalar
+	if overflow := sv.SetByteSlice(sig[32:64]); overflow || sv.IsOverHalfOrder() {
+		return "", ErrInvalidSignature
+	}
+	compact := make([]byte, 65)
+	compact[0] = 27 + v
+	copy(compact[1:], sig[:64])
+	pub, _, err := ecdsa.RecoverCompact(compact, PersonalMessageHash(message))
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	return PubKeyAddress(pub), nil
+}
+
+// GenerateKey returns a fresh secp256k1 key and its checksummed address.
+func GenerateKey() (*secp256k1.PrivateKey, string, error) {
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return nil, "", err
+	}
+	return key, PubKeyAddress(key.PubKey()), nil
+}
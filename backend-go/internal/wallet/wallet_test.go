@@ -0,0 +1,157 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// Vector from the web3.js accounts.sign documentation: the key
+// 0x4c0883a6...362318 signing "Some data".
+const (
+	vectorAddress   = "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"
+	vectorMessage   = "Some data"
+	vectorHash      = "1da44b586eb0729ff70a73c326926f6ed5a25f5b056e7f47fbc6e58d86871655"
+	vectorSignature = "0xb91467e570a6466aa9e9876cbcd013baba02900b8979d43fe208a4a4f339f5fd6007e74cd82e037b800186422fc2da167c747ef045e5d18a5f5d4300f8e1a0291c"
+)
+
+func TestPersonalMessageHash(t *testing.T) {
+	if got := hex.EncodeToString(PersonalMessageHash(vectorMessage)); got != vectorHash {
+		t.Fatalf("hash = %s, want %s", got, vectorHash)
+	}
+}
+
+func TestRecoverPersonalSign(t *testing.T) {
+	// The vector's v is 28; the same signature with v as 0/1 must recover too.
+	lowV := vectorSignature[:len(vectorSignature)-2] + "01"
+	tests := []struct {
+		name      string
+		message   string
+		signature string
+		want      string
+		err       error
+	}{
+		{"known vector", vectorMessage, vectorSignature, vectorAddress, nil},
+		{"v as 0/1", vectorMessage, lowV, vectorAddress, nil},
+		{"no 0x prefix", vectorMessage, strings.TrimPrefix(vectorSignature, "0x"), vectorAddress, nil},
+		{"wrong v", vectorMessage, vectorSignature[:len(vectorSignature)-2] + "1d", "", ErrInvalidSignature},
+		{"short", vectorMessage, vectorSignature[:len(vectorSignature)-2], "", ErrInvalidSignature},
+		{"long", vectorMessage, vectorSignature + "00", "", ErrInvalidSignature},
+		{"not hex", vectorMessage, "0xzz" + vectorSignature[4:], "", ErrInvalidSignature},
+		{"empty", vectorMessage, "", "", ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RecoverPersonalSign(tt.message, tt.signature)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Fatalf("address = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecoverPersonalSignHighS(t *testing.T) {
+	// (r, n-s) with the recovery id flipped is the same signature's
+	// malleated twin; it recovers the same key but must be refused.
+	sig, _ := hex.DecodeString(strings.TrimPrefix(vectorSignature, "0x"))
+	var s secp256k1.ModNScalar
+	s.SetByteSlice(sig[32:64])
+	s.Negate()
+	high := s.Bytes()
+	copy(sig[32:64], high[:])
+	sig[64] ^= 1
+	if _, err := RecoverPersonalSign(vectorMessage, "0x"+hex.EncodeToString(sig)); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("err = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestRecoverPersonalSignTampered(t *testing.T) {
+	// A valid signature over a different message recovers some other
+	// address, never the signer's.
+	got, err := RecoverPersonalSign(vectorMessage+".", vectorSignature)
+	if err == nil && got == vectorAddress {
+		t.Fatalf("tampered message recovered the signer %s", got)
+	}
+}
+
+func TestRecoverPersonalSignGeneratedKey(t *testing.T) {
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := PubKeyAddress(key.PubKey())
+	message := "autotrade sign-in"
+	sig := personalSign(key, message)
+	if got, err := RecoverPersonalSign(message, sig); err != nil || got != address {
+		t.Fatalf("recovered %s, %v; want %s", got, err, address)
+	}
+	if got, _ := RecoverPersonalSign(message, personalSign(other, message)); got == address {
+		t.Fatalf("another key's signature recovered %s", address)
+	}
+}
+
+// personalSign returns a hex r||s||v signature with v as 27/28, like wallets do.
+func personalSign(key *secp256k1.PrivateKey, message string) string {
+	compact := ecdsa.SignCompact(key, PersonalMessageHash(message), false)
+	sig := append(append([]byte{}, compact[1:]...), compact[0])
+	return "0x" + hex.EncodeToString(sig)
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+		err  error
+	}{
+		{"checksummed", vectorAddress, vectorAddress, nil},
+		{"lowercase", strings.ToLower(vectorAddress), vectorAddress, nil},
+		{"uppercase", "0x" + strings.ToUpper(vectorAddress[2:]), vectorAddress, nil},
+		{"upper 0X prefix", "0X" + vectorAddress[2:], vectorAddress, nil},
+		{"surrounding space", " " + vectorAddress + "\n", vectorAddress, nil},
+		// EIP-55 test vectors.
+		{"eip55 vector", "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", nil},
+		{"eip55 vector 2", "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", nil},
+		{"bad checksum", "0x2c7536e3605D9C16a7a3D7b1898e529396a65c23", "", ErrInvalidChecksum},
+		{"no prefix", vectorAddress[2:] + "00", "", ErrInvalidAddress},
+		{"too short", vectorAddress[:41], "", ErrInvalidAddress},
+		{"too long", vectorAddress + "0", "", ErrInvalidAddress},
+		{"not hex", "0x2c7536E3605D9C16a7a3D7b1898e529396a65cZZ", "", ErrInvalidAddress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeAddress(tt.in)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Fatalf("address = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecoverPersonalSignWrongAddress(t *testing.T) {
+	want, err := NormalizeAddress("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := RecoverPersonalSign(vectorMessage, vectorSignature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == want {
+		t.Fatalf("signature recovered unrelated address %s", want)
+	}
+}
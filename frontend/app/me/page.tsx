@@ -3,7 +3,17 @@
 import { ConnectButton } from "@rainbow-me/rainbowkit";
 import { useEffect, useState } from "react";
 import { useAccount, useSignMessage } from "wagmi";
-import { approveAgent, connectWallet, fetchWalletSession, WalletSession } from "../../lib/api";
+import {
+  approveAgent,
+  buildSiweMessage,
+  connectWallet,
+  fetchLedger,
+  fetchWalletNonce,
+  fetchWalletSession,
+  LedgerEntry,
+  revokeAgent,
+  WalletSession
+} from "../../lib/api";
 
 const EMPTY_WALLET: WalletSession = {
   address: "",
@@ -19,10 +29,12 @@ export default function MePage() {
   const [wallet, setWallet] = useState<WalletSession>(EMPTY_WALLET);
   const [busy, setBusy] = useState(false);
   const [error, setError] = useState("");
+  const [ledger, setLedger] = useState<LedgerEntry[]>([]);
 
   const refresh = async () => {
     const ws = await fetchWalletSession();
     setWallet(ws);
+    if (ws.connected) setLedger(await fetchLedger({ limit: 50 }));
   };
 
   useEffect(() => {
@@ -34,7 +46,7 @@ export default function MePage() {
     setError("");
     try {
       if (!isConnected || !address) throw new Error("请先连接钱包");
-      const message = `HyperClaw login nonce ${Date.now()}`;
+      const message = buildSiweMessage(address, await fetchWalletNonce());
       const signature = await signMessageAsync({ message });
       await connectWallet({ address, signature, message });
       await refresh();
@@ -58,6 +70,19 @@ export default function MePage() {
     }
   };
 
+  const onRevokeAgent = async () => {
+    setBusy(true);
+    setError("");
+    try {
+      await revokeAgent();
+      await refresh();
+    } catch (e: any) {
+      setError(e.message || "撤销失败");
+    } finally {
+      setBusy(false);
+    }
+  };
+
   return (
     <main className="container page-content">
       <header>
@@ -72,12 +97,30 @@ export default function MePage() {
         <p className="mono">会话地址: {wallet.address || "未绑定"}</p>
         <p>会话状态: {wallet.connected ? "已绑定" : "未绑定"}</p>
         <p>Agent 状态: {wallet.agentApproved ? "已授权" : "未授权"}</p>
+        <p className="mono">Agent 地址: {wallet.agentPubKey || "无"}</p>
         <div className="actions">
           <button disabled={busy || !isConnected} onClick={onBindWallet}>签名绑定会话</button>
-          <button disabled={busy || !wallet.connected} onClick={onApproveAgent}>授权 Agent</button>
+          <button disabled={busy || !wallet.connected} onClick={onApproveAgent}>
+            {wallet.agentApproved ? "轮换 Agent" : "授权 Agent"}
+          </button>
+          <button disabled={busy || !wallet.agentApproved} onClick={onRevokeAgent}>撤销 Agent</button>
         </div>
         {error ? <p className="errorline">{error}</p> : null}
       </section>
+
+      <section className="card block">
+        <h3>资金流水</h3>
+        <div className="list">
+          {ledger.map((e) => (
+            <div key={e.id} className="item">
+              <strong>{e.kind}{e.symbol ? ` ${e.symbol}` : ""}</strong>
+              <span>{e.amount.toFixed(2)} → 余额 {e.balance.toFixed(2)}</span>
+              <span>{new Date(e.createdAt).toLocaleString()}</span>
+            </div>
+          ))}
+          {ledger.length === 0 ? <p>暂无流水</p> : null}
+        </div>
+      </section>
     </main>
   );
 }
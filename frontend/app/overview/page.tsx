@@ -22,6 +22,8 @@ const EMPTY_WALLET: WalletSession = {
 export default function OverviewPage() {
   const [equity, setEquity] = useState(0);
   const [leverage, setLeverage] = useState(0);
+  const [openPnl, setOpenPnl] = useState(0);
+  const [freeMargin, setFreeMargin] = useState(0);
   const [status, setStatus] = useState<StrategyStatus>(EMPTY_STATUS);
   const [wallet, setWallet] = useState<WalletSession>(EMPTY_WALLET);
 
@@ -34,6 +36,8 @@ export default function OverviewPage() {
       ]);
       setEquity(state.state.equity ?? 0);
       setLeverage(state.state.leverage ?? 0);
+      setOpenPnl(state.state.openPnl ?? 0);
+      setFreeMargin(state.state.freeMargin ?? 0);
       setStatus(strategy);
       setWallet(session);
     };
@@ -51,6 +55,8 @@ export default function OverviewPage() {
         <div className="statline">
           <span>净值 {equity.toFixed(2)}</span>
           <span>杠杆 {leverage.toFixed(1)}x</span>
+          <span>浮动盈亏 {openPnl.toFixed(2)}</span>
+          <span>可用保证金 {freeMargin.toFixed(2)}</span>
           <span>Bias {status.bias}</span>
         </div>
       </header>
@@ -1,7 +1,21 @@
 "use client";
 
 import { useEffect, useMemo, useState } from "react";
-import { fetchOrders, fetchWalletSession, Order, placeOrder, WalletSession } from "../../lib/api";
+import {
+  ApiError,
+  cancelOrder,
+  fetchOrders,
+  fetchPositions,
+  fetchWalletSession,
+  Order,
+  placeOrder,
+  Position,
+  SizeQuote,
+  sizePosition,
+  WalletSession
+} from "../../lib/api";
+
+const CANCELLABLE = ["pending", "open", "partially_filled"];
 
 const EMPTY_WALLET: WalletSession = {
   address: "",
@@ -13,12 +27,18 @@ const EMPTY_WALLET: WalletSession = {
 export default function TradePage() {
   const [wallet, setWallet] = useState<WalletSession>(EMPTY_WALLET);
   const [orders, setOrders] = useState<Order[]>([]);
+  const [positions, setPositions] = useState<Position[]>([]);
   const [error, setError] = useState("");
+  const [fieldErrors, setFieldErrors] = useState<Record<string, string>>({});
   const [busy, setBusy] = useState(false);
 
   const [symbol, setSymbol] = useState("BTC");
   const [side, setSide] = useState<"Buy" | "Sell">("Buy");
+  const [orderType, setOrderType] = useState<"market" | "limit" | "stop">("market");
+  const [execution, setExecution] = useState<"paper" | "live">("paper");
   const [size, setSize] = useState(0.002);
+  const [riskPct, setRiskPct] = useState(1);
+  const [quote, setQuote] = useState<SizeQuote | null>(null);
   const [entryPrice, setEntryPrice] = useState(100000);
   const [stopLoss, setStopLoss] = useState(99500);
   const [takeProfit, setTakeProfit] = useState(100800);
@@ -26,9 +46,10 @@ export default function TradePage() {
   const canTrade = useMemo(() => wallet.connected && wallet.agentApproved, [wallet]);
 
   const refresh = async () => {
-    const [session, list] = await Promise.all([fetchWalletSession(), fetchOrders(20)]);
+    const [session, list, open] = await Promise.all([fetchWalletSession(), fetchOrders(20), fetchPositions()]);
     setWallet(session);
     setOrders(list);
+    setPositions(open);
   };
 
   useEffect(() => {
@@ -40,20 +61,56 @@ export default function TradePage() {
   const onPlaceOrder = async () => {
     setBusy(true);
     setError("");
+    setFieldErrors({});
     try {
       await placeOrder({
         symbol,
         side,
-        orderType: "market",
+        orderType,
         size,
         entryPrice,
         stopLoss,
         takeProfit,
-        execution: "paper"
+        execution,
+        clientTag: crypto.randomUUID()
       });
       await refresh();
     } catch (e: any) {
-      setError(e.message || "下单失败");
+      if (e instanceof ApiError) setFieldErrors(e.fields);
+      if (e instanceof ApiError && e.rejections.length) {
+        setError(`风控拒绝: ${e.rejections.map((r) => r.message).join("; ")}`);
+      } else {
+        setError(e.message || "下单失败");
+      }
+    } finally {
+      setBusy(false);
+    }
+  };
+
+  const onSizeByRisk = async () => {
+    setBusy(true);
+    setError("");
+    setFieldErrors({});
+    try {
+      const q = await sizePosition({ execution, symbol, side, entryPrice, stopLoss, riskPct });
+      setQuote(q);
+      setSize(q.size);
+    } catch (e: any) {
+      if (e instanceof ApiError) setFieldErrors(e.fields);
+      setError(e.message || "仓位计算失败");
+    } finally {
+      setBusy(false);
+    }
+  };
+
+  const onCancelOrder = async (id: number) => {
+    setBusy(true);
+    setError("");
+    try {
+      await cancelOrder(id);
+      await refresh();
+    } catch (e: any) {
+      setError(e.message || "撤单失败");
     } finally {
       setBusy(false);
     }
@@ -77,24 +134,64 @@ export default function TradePage() {
               <option>Sell</option>
             </select>
           </label>
+          <label>Type
+            <select value={orderType} onChange={(e) => setOrderType(e.target.value as "market" | "limit" | "stop")}>
+              <option value="market">market</option>
+              <option value="limit">limit</option>
+              <option value="stop">stop</option>
+            </select>
+          </label>
+          <label>Execution
+            <select value={execution} onChange={(e) => setExecution(e.target.value as "paper" | "live")}>
+              <option value="paper">paper</option>
+              <option value="live">live (Hyperliquid)</option>
+            </select>
+          </label>
           <label>Size<input type="number" value={size} onChange={(e) => setSize(Number(e.target.value))} /></label>
           <label>Entry<input type="number" value={entryPrice} onChange={(e) => setEntryPrice(Number(e.target.value))} /></label>
           <label>StopLoss<input type="number" value={stopLoss} onChange={(e) => setStopLoss(Number(e.target.value))} /></label>
           <label>TakeProfit<input type="number" value={takeProfit} onChange={(e) => setTakeProfit(Number(e.target.value))} /></label>
+          <label>Risk %<input type="number" value={riskPct} onChange={(e) => setRiskPct(Number(e.target.value))} /></label>
         </div>
+        <button disabled={busy} onClick={onSizeByRisk}>按风险计算仓位</button>
+        {quote ? (
+          <p className="mono">
+            size {quote.size} (lot {quote.lotSize}) | notional {quote.notional.toFixed(2)} | {quote.leverage.toFixed(2)}x | loss@SL {quote.lossAtStop.toFixed(2)}
+          </p>
+        ) : null}
+        {Object.entries(fieldErrors).map(([field, msg]) => (
+          <p key={field} className="errorline">{field}: {msg}</p>
+        ))}
         <button disabled={busy || !canTrade} onClick={onPlaceOrder}>提交订单</button>
         {error ? <p className="errorline">{error}</p> : null}
       </section>
 
+      <section className="card block">
+        <h3>持仓</h3>
+        <div className="list">
+          {positions.map((p) => (
+            <div key={p.symbol} className="item">
+              <strong>{p.symbol} {p.side}</strong>
+              <span>{p.size} @ {p.avgEntry.toFixed(2)} | mark {p.markPrice.toFixed(2)}{p.markStale ? " (stale)" : ""}</span>
+              <span>浮动 {p.unrealizedPnl.toFixed(2)} | 已实现 {p.realizedPnl.toFixed(2)} | 资金费 {p.cumulativeFunding.toFixed(2)}</span>
+            </div>
+          ))}
+          {positions.length === 0 ? <p>暂无持仓</p> : null}
+        </div>
+      </section>
+
       <section className="card block">
         <h3>订单流</h3>
         <div className="list">
           {orders.map((o) => (
             <div key={o.id} className="item">
-              <strong>{o.symbol} {o.side}</strong>
-              <span>{o.execution} | {o.size} @ {o.entryPrice}</span>
+              <strong>{o.symbol} {o.side} {o.orderType}{o.role !== "entry" ? ` (${o.role} of #${o.parentId})` : ""}</strong>
+              <span>{o.execution}{o.venueOrderId && o.execution === "live" ? ` #${o.venueOrderId}` : ""} | {o.status} | {o.filledSize}/{o.size} @ {o.entryPrice}</span>
               <span>SL {o.stopLoss} / TP {o.takeProfit}</span>
               <span>{new Date(o.createdAt).toLocaleString()}</span>
+              {CANCELLABLE.includes(o.status) ? (
+                <button disabled={busy} onClick={() => onCancelOrder(o.id)}>撤单</button>
+              ) : null}
             </div>
           ))}
           {orders.length === 0 ? <p>暂无订单</p> : null}
@@ -4,6 +4,51 @@ const API_BASE =
     ? "/api"
     : process.env.API_BASE_INTERNAL || "http://localhost:8080");
 
+const SESSION_KEY = "hyperclaw.session";
+
+export function getSessionToken() {
+  if (typeof window === "undefined") return "";
+  return window.localStorage.getItem(SESSION_KEY) || "";
+}
+
+export function setSessionToken(token: string) {
+  if (typeof window === "undefined") return;
+  if (token) window.localStorage.setItem(SESSION_KEY, token);
+  else window.localStorage.removeItem(SESSION_KEY);
+}
+
+export type RiskRejection = {
+  rule: string;
+  message: string;
+  limit: number;
+  actual: number;
+};
+
+export class ApiError extends Error {
+  fields: Record<string, string>;
+  rejections: RiskRejection[];
+  code: string;
+
+  constructor(message: string, fields: Record<string, string> = {}, rejections: RiskRejection[] = [], code = "") {
+    super(message);
+    this.fields = fields;
+    this.rejections = rejections;
+    this.code = code;
+  }
+}
+
+async function apiError(res: Response, fallback: string) {
+  const data = await res.json().catch(() => ({}));
+  return new ApiError(data.error || fallback, data.fields || {}, data.rejections || [], data.code || "");
+}
+
+function apiFetch(path: string, init: RequestInit = {}) {
+  const headers = new Headers(init.headers);
+  const token = getSessionToken();
+  if (token) headers.set("Authorization", `Bearer ${token}`);
+  return fetch(`${API_BASE}${path}`, { ...init, headers });
+}
+
 export type Fill = {
   id: number;
   symbol: string;
@@ -11,7 +56,43 @@ export type Fill = {
   price: number;
   size: number;
   realizedPnl: number;
+  fee: number;
+  slippage: number;
+  liquidity: string;
   status: string;
+  venueTradeId: string;
+  createdAt: string;
+};
+
+export type Execution = "paper" | "live";
+
+export type Position = {
+  execution: Execution;
+  symbol: string;
+  side: string;
+  size: number;
+  avgEntry: number;
+  realizedPnl: number;
+  cumulativeFunding: number;
+  openedAt: string | null;
+  updatedAt: string;
+  markPrice: number;
+  markedAt: string | null;
+  markAgeSecs: number;
+  markStale: boolean;
+  unrealizedPnl: number;
+};
+
+export type LedgerEntry = {
+  id: number;
+  execution: Execution;
+  kind: "deposit" | "withdrawal" | "realised_pnl" | "fee" | "funding" | "adjustment";
+  amount: number;
+  balance: number;
+  symbol: string;
+  refType: string;
+  refId: number | null;
+  note: string;
   createdAt: string;
 };
 
@@ -20,6 +101,7 @@ export type WalletSession = {
   connected: boolean;
   agentApproved: boolean;
   agentPubKey: string;
+  agentValidUntil?: string | null;
   updatedAt?: string;
 };
 
@@ -47,28 +129,53 @@ export type Order = {
   side: string;
   orderType: string;
   size: number;
+  filledSize: number;
   entryPrice: number;
   stopLoss: number;
   takeProfit: number;
   status: string;
   execution: string;
+  venueOrderId: string;
+  parentId: number | null;
+  role: "entry" | "stop_loss" | "take_profit";
   createdAt: string;
+  updatedAt: string;
 };
 
 export async function fetchFills(limit = 20): Promise<Fill[]> {
-  const res = await fetch(`${API_BASE}/v1/me/fills?limit=${limit}`, { cache: "no-store" });
+  const res = await apiFetch(`/v1/me/fills?limit=${limit}`, { cache: "no-store" });
   if (!res.ok) throw new Error("failed to fetch fills");
   const data = await res.json();
   return data.fills || [];
 }
 
+export async function fetchPositions(): Promise<Position[]> {
+  const res = await apiFetch(`/v1/me/positions`, { cache: "no-store" });
+  if (!res.ok) throw new Error("failed to fetch positions");
+  const data = await res.json();
+  return data.positions || [];
+}
+
+export async function fetchLedger(
+  params: { execution?: Execution; from?: string; to?: string; kind?: string; limit?: number } = {}
+): Promise<LedgerEntry[]> {
+  const query = new URLSearchParams();
+  Object.entries(params).forEach(([k, v]) => {
+    if (v !== undefined && v !== "") query.set(k, String(v));
+  });
+  const res = await apiFetch(`/v1/me/ledger?${query.toString()}`, { cache: "no-store" });
+  if (!res.ok) throw new Error("failed to fetch ledger");
+  const data = await res.json();
+  return data.entries || [];
+}
+
 export async function submitReview(payload: {
   fillId: number;
   verdict: "good" | "bad";
   tags: string[];
   notes: string;
 }) {
-  const res = await fetch(`${API_BASE}/v1/me/review`, {
+  const res = await apiFetch(`/v1/me/review`, {
     method: "POST",
     headers: { "Content-Type": "application/json" },
     body: JSON.stringify(payload)
@@ -80,51 +187,90 @@ export async function submitReview(payload: {
   return res.json();
 }
 
-export async function fetchState() {
-  const res = await fetch(`${API_BASE}/v1/me/state`, { cache: "no-store" });
+export async function fetchState(execution: Execution = "paper") {
+  const res = await apiFetch(`/v1/me/state?execution=${execution}`, { cache: "no-store" });
   if (!res.ok) throw new Error("failed to fetch state");
   return res.json();
 }
 
 export async function fetchWalletSession(): Promise<WalletSession> {
-  const res = await fetch(`${API_BASE}/v1/auth/wallet/session`, { cache: "no-store" });
+  const res = await apiFetch(`/v1/auth/wallet/session`, { cache: "no-store" });
   if (!res.ok) throw new Error("failed to fetch wallet session");
   const data = await res.json();
   return data.session || { address: "", connected: false, agentApproved: false, agentPubKey: "" };
 }
 
+export type WalletNonce = {
+  nonce: string;
+  domain: string;
+  chainId: number;
+  issuedAt: string;
+  expiresAt: string;
+};
+
+export async function fetchWalletNonce(): Promise<WalletNonce> {
+  const res = await apiFetch(`/v1/auth/wallet/nonce`, { cache: "no-store" });
+  if (!res.ok) throw new Error("failed to fetch wallet nonce");
+  return res.json();
+}
+
+export function buildSiweMessage(address: string, nonce: WalletNonce) {
+  return [
+    `${nonce.domain} wants you to sign in with your Ethereum account:`,
+    address,
+    "",
+    "Sign in to HyperClaw.",
+    "",
+    `URI: ${window.location.origin}`,
+    "Version: 1",
+    `Chain ID: ${nonce.chainId}`,
+    `Nonce: ${nonce.nonce}`,
+    `Issued At: ${nonce.issuedAt}`,
+    `Expiration Time: ${nonce.expiresAt}`
+  ].join("\n");
+}
+
 export async function connectWallet(payload: { address: string; signature: string; message: string }) {
-  const res = await fetch(`${API_BASE}/v1/auth/wallet/connect`, {
+  const res = await apiFetch(`/v1/auth/wallet/connect`, {
     method: "POST",
     headers: { "Content-Type": "application/json" },
     body: JSON.stringify(payload)
   });
-  if (!res.ok) throw new Error((await res.json().catch(() => ({}))).error || "connect wallet failed");
-  return res.json();
+  // code is signer_mismatch, nonce_invalid, message_rejected or message_expired on 401.
+  if (!res.ok) throw await apiError(res, "connect wallet failed");
+  const data = await res.json();
+  setSessionToken(data.session?.token || "");
+  return data;
 }
 
 export async function approveAgent() {
-  const res = await fetch(`${API_BASE}/v1/auth/approve-agent`, { method: "POST" });
+  const res = await apiFetch(`/v1/auth/approve-agent`, { method: "POST" });
   if (!res.ok) throw new Error((await res.json().catch(() => ({}))).error || "approve agent failed");
   return res.json();
 }
 
+export async function revokeAgent() {
+  const res = await apiFetch(`/v1/auth/agent`, { method: "DELETE" });
+  if (!res.ok) throw new Error((await res.json().catch(() => ({}))).error || "revoke agent failed");
+  return res.json();
+}
+
 export async function fetchStrategyStatus(): Promise<StrategyStatus> {
-  const res = await fetch(`${API_BASE}/v1/strategy/status`, { cache: "no-store" });
+  const res = await apiFetch(`/v1/strategy/status`, { cache: "no-store" });
   if (!res.ok) throw new Error("failed to fetch strategy status");
   const data = await res.json();
   return data.status;
 }
 
 export async function fetchStrategyDerives(): Promise<StrategyDerive[]> {
-  const res = await fetch(`${API_BASE}/v1/strategy/derives`, { cache: "no-store" });
+  const res = await apiFetch(`/v1/strategy/derives`, { cache: "no-store" });
   if (!res.ok) throw new Error("failed to fetch strategy derives");
   const data = await res.json();
   return data.derives || [];
 }
 
 export async function setAutoTrading(enabled: boolean) {
-  const res = await fetch(`${API_BASE}/v1/strategy/auto-trade`, {
+  const res = await apiFetch(`/v1/strategy/auto-trade`, {
     method: "PATCH",
     headers: { "Content-Type": "application/json" },
     body: JSON.stringify({ enabled })
@@ -134,7 +280,7 @@ export async function setAutoTrading(enabled: boolean) {
 }
 
 export async function setBias(bias: "Long" | "Short" | "Hybrid") {
-  const res = await fetch(`${API_BASE}/v1/control/bias`, {
+  const res = await apiFetch(`/v1/control/bias`, {
     method: "PATCH",
     headers: { "Content-Type": "application/json" },
     body: JSON.stringify({ bias })
@@ -143,29 +289,79 @@ export async function setBias(bias: "Long" | "Short" | "Hybrid") {
   return res.json();
 }
 
-export async function placeOrder(payload: {
+export type SizeQuote = {
   symbol: string;
-  side: "Buy" | "Sell";
-  orderType: "market" | "limit";
+  side: string;
   size: number;
+  lotSize: number;
+  riskAmount: number;
+  notional: number;
+  leverage: number;
+  lossAtStop: number;
+  equity: number;
+};
+
+export async function sizePosition(payload: {
+  execution?: Execution;
+  symbol: string;
+  side: "Buy" | "Sell";
+  entryPrice: number;
+  stopLoss: number;
+  riskPct?: number;
+  riskAmount?: number;
+}): Promise<SizeQuote> {
+  const res = await apiFetch(`/v1/trade/size`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(payload)
+  });
+  if (!res.ok) throw await apiError(res, "size position failed");
+  return res.json();
+}
+
+export async function placeOrder(payload: {
+  symbol: string;
+  side: "Buy" | "Sell" | "long" | "short";
+  orderType: "market" | "limit" | "stop";
+  size?: number;
+  riskPct?: number;
   entryPrice: number;
   stopLoss: number;
   takeProfit: number;
   execution: "paper" | "live";
   clientTag?: string;
 }) {
-  const res = await fetch(`${API_BASE}/v1/trade/order`, {
+  const res = await apiFetch(`/v1/trade/order`, {
     method: "POST",
     headers: { "Content-Type": "application/json" },
     body: JSON.stringify(payload)
   });
-  if (!res.ok) throw new Error((await res.json().catch(() => ({}))).error || "place order failed");
+  if (!res.ok) throw await apiError(res, "place order failed");
   return res.json();
 }
 
 export async function fetchOrders(limit = 20): Promise<Order[]> {
-  const res = await fetch(`${API_BASE}/v1/trade/orders?limit=${limit}`, { cache: "no-store" });
+  const res = await apiFetch(`/v1/trade/orders?limit=${limit}`, { cache: "no-store" });
   if (!res.ok) throw new Error("failed to fetch orders");
   const data = await res.json();
   return data.orders || [];
 }
+
+export async function cancelOrder(id: number) {
+  const res = await apiFetch(`/v1/trade/orders/${id}/cancel`, { method: "POST" });
+  if (!res.ok) throw new Error((await res.json().catch(() => ({}))).error || "cancel order failed");
+  return res.json();
+}
+
+export async function amendOrder(
+  id: number,
+  payload: { entryPrice?: number; size?: number; stopLoss?: number; takeProfit?: number }
+) {
+  const res = await apiFetch(`/v1/trade/orders/${id}`, {
+    method: "PATCH",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(payload)
+  });
+  if (!res.ok) throw await apiError(res, "amend order failed");
+  return res.json();
+}
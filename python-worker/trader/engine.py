@@ -15,29 +15,41 @@ class TraderEngine:
     async def loop(self) -> None:
         while True:
             try:
-                await self.trade_once()
+                accounts = await self._accounts()
             except Exception as exc:  # pragma: no cover
-                logging.exception("auto-trader cycle failed: %s", exc)
-                await self._set_runtime_error(str(exc))
+                logging.exception("auto-trader account scan failed: %s", exc)
+                accounts = []
+            for account in accounts:
+                try:
+                    await self.trade_once(account)
+                except Exception as exc:  # pragma: no cover
+                    logging.exception("auto-trader cycle failed for %s: %s", account, exc)
+                    await self._set_runtime_error(account, str(exc))
             await asyncio.sleep(self.interval_seconds)
 
-    async def trade_once(self) -> None:
+    async def _accounts(self) -> list[str]:
+        async with self.db.connect() as conn:
+            rows = await conn.execute(text("SELECT account FROM control_state WHERE account <> ''"))
+            return [r[0] for r in rows]
+
+    async def trade_once(self, account: str) -> None:
         async with self.db.begin() as conn:
             row = (
                 await conn.execute(
                     text(
                         """
-                        SELECT c.auto_trading, COALESCE(ws.agent_approved, false)
+                        SELECT c.auto_trading, EXISTS (
+                            SELECT 1
+                            FROM agent_keys ak
+                            WHERE ak.account = c.account AND ak.status = 'active'
+                              AND ak.revoked_at IS NULL
+                              AND (ak.valid_until IS NULL OR ak.valid_until > now())
+                        )
                         FROM control_state c
-                        LEFT JOIN LATERAL (
-                            SELECT agent_approved
-                            FROM wallet_sessions
-                            ORDER BY updated_at DESC
-                            LIMIT 1
-                        ) ws ON true
-                        WHERE c.id = 1
+                        WHERE c.account = :account
                         """
-                    )
+                    ),
+                    {"account": account},
                 )
             ).first()
 
@@ -47,7 +59,10 @@ class TraderEngine:
             auto_trading, agent_approved = bool(row[0]), bool(row[1])
             if not auto_trading:
                 await conn.execute(
-                    text("UPDATE strategy_runtime SET runtime_status = 'paused', updated_at = now() WHERE id = 1")
+                    text(
+                        "UPDATE strategy_runtime SET runtime_status = 'paused', updated_at = now() WHERE account = :account"
+                    ),
+                    {"account": account},
                 )
                 return
 
@@ -57,9 +72,10 @@ class TraderEngine:
                         """
                         UPDATE strategy_runtime
                         SET runtime_status = 'blocked', last_error = 'agent not approved', updated_at = now()
-                        WHERE id = 1
+                        WHERE account = :account
                         """
-                    )
+                    ),
+                    {"account": account},
                 )
                 return
 
@@ -89,24 +105,40 @@ class TraderEngine:
             sl = close_px * (0.995 if side == "Buy" else 1.005)
             tp = close_px * (1.008 if side == "Buy" else 0.992)
 
+            # Left pending: the API's paper matcher runs the same validation and
+            # risk checks as for API orders before opening or rejecting it.
+            order_id = (
+                await conn.execute(
+                    text(
+                        """
+                        INSERT INTO orders
+                        (account, symbol, side, order_type, size, entry_price, stop_loss, take_profit, status, execution, client_tag)
+                        VALUES
+                        (:account, :symbol, :side, 'market', :size, :entry, :sl, :tp, 'pending', 'paper-auto', :tag)
+                        RETURNING id
+                        """
+                    ),
+                    {
+                        "account": account,
+                        "symbol": symbol,
+                        "side": side,
+                        "size": size,
+                        "entry": close_px,
+                        "sl": sl,
+                        "tp": tp,
+                        "tag": f"auto-{datetime.now(timezone.utc).strftime('%H%M%S')}",
+                    },
+                )
+            ).scalar_one()
+
             await conn.execute(
                 text(
                     """
-                    INSERT INTO orders
-                    (symbol, side, order_type, size, entry_price, stop_loss, take_profit, status, execution, client_tag)
-                    VALUES
-                    (:symbol, :side, 'market', :size, :entry, :sl, :tp, 'open', 'paper-auto', :tag)
+                    INSERT INTO order_events (account, order_id, event, from_status, to_status, detail)
+                    VALUES (:account, :order_id, 'created', '', 'pending', CAST(:detail AS JSONB))
                     """
                 ),
-                {
-                    "symbol": symbol,
-                    "side": side,
-                    "size": size,
-                    "entry": close_px,
-                    "sl": sl,
-                    "tp": tp,
-                    "tag": f"auto-{datetime.now(timezone.utc).strftime('%H%M%S')}",
-                },
+                {"account": account, "order_id": order_id, "detail": '{"source": "auto-trader"}'},
             )
 
             await conn.execute(
@@ -117,17 +149,18 @@ class TraderEngine:
                         last_signal = :signal,
                         last_error = '',
                         updated_at = now()
-                    WHERE id = 1
+                    WHERE account = :account
                     """
                 ),
                 {
+                    "account": account,
                     "signal": f"{symbol} {side} vol={volatility:.4f}",
                 },
             )
 
-            logging.info("auto-trader placed paper order: %s %s", symbol, side)
+            logging.info("auto-trader submitted paper order %s for %s: %s %s", order_id, account, symbol, side)
 
-    async def _set_runtime_error(self, message: str) -> None:
+    async def _set_runtime_error(self, account: str, message: str) -> None:
         async with self.db.begin() as conn:
             await conn.execute(
                 text(
@@ -136,8 +169,8 @@ class TraderEngine:
                     SET runtime_status = 'error',
                         last_error = :msg,
                         updated_at = now()
-                    WHERE id = 1
+                    WHERE account = :account
                     """
                 ),
-                {"msg": message[:160]},
+                {"account": account, "msg": message[:160]},
             )